@@ -0,0 +1,134 @@
+package pgqb
+
+// Standard aggregate functions.
+
+func Count(exp interface{}) *FuncCallNode {
+	return FuncCall("count", exp)
+}
+
+// count(*)
+func CountAll() *FuncCallNode {
+	return FuncCall("count", SQL("*"))
+}
+
+func Sum(exp interface{}) *FuncCallNode {
+	return FuncCall("sum", exp)
+}
+
+func Avg(exp interface{}) *FuncCallNode {
+	return FuncCall("avg", exp)
+}
+
+func Min(exp interface{}) *FuncCallNode {
+	return FuncCall("min", exp)
+}
+
+func Max(exp interface{}) *FuncCallNode {
+	return FuncCall("max", exp)
+}
+
+func ArrayAgg(exp interface{}) *FuncCallNode {
+	return FuncCall("array_agg", exp)
+}
+
+func StringAgg(exp, delimiter interface{}) *FuncCallNode {
+	return FuncCall("string_agg", exp, delimiter)
+}
+
+func JsonAgg(exp interface{}) *FuncCallNode {
+	return FuncCall("json_agg", exp)
+}
+
+func JsonbAgg(exp interface{}) *FuncCallNode {
+	return FuncCall("jsonb_agg", exp)
+}
+
+func JsonObjectAgg(key, value interface{}) *FuncCallNode {
+	return FuncCall("json_object_agg", key, value)
+}
+
+func JsonbObjectAgg(key, value interface{}) *FuncCallNode {
+	return FuncCall("jsonb_object_agg", key, value)
+}
+
+func BoolAnd(exp interface{}) *FuncCallNode {
+	return FuncCall("bool_and", exp)
+}
+
+func BoolOr(exp interface{}) *FuncCallNode {
+	return FuncCall("bool_or", exp)
+}
+
+func Every(exp interface{}) *FuncCallNode {
+	return FuncCall("every", exp)
+}
+
+func BitAndAgg(exp interface{}) *FuncCallNode {
+	return FuncCall("bit_and", exp)
+}
+
+func BitOrAgg(exp interface{}) *FuncCallNode {
+	return FuncCall("bit_or", exp)
+}
+
+// Statistical aggregates.
+
+func Stddev(exp interface{}) *FuncCallNode {
+	return FuncCall("stddev", exp)
+}
+
+func StddevPop(exp interface{}) *FuncCallNode {
+	return FuncCall("stddev_pop", exp)
+}
+
+func StddevSamp(exp interface{}) *FuncCallNode {
+	return FuncCall("stddev_samp", exp)
+}
+
+func Variance(exp interface{}) *FuncCallNode {
+	return FuncCall("variance", exp)
+}
+
+func VarPop(exp interface{}) *FuncCallNode {
+	return FuncCall("var_pop", exp)
+}
+
+func VarSamp(exp interface{}) *FuncCallNode {
+	return FuncCall("var_samp", exp)
+}
+
+func Corr(y, x interface{}) *FuncCallNode {
+	return FuncCall("corr", y, x)
+}
+
+// Ordered-set aggregates.
+
+func PercentileCont(fraction interface{}, orderBy ... interface{}) *FuncCallNode {
+	return FuncCall("percentile_cont", fraction).WithinGroup(orderBy...)
+}
+
+func PercentileDisc(fraction interface{}, orderBy ... interface{}) *FuncCallNode {
+	return FuncCall("percentile_disc", fraction).WithinGroup(orderBy...)
+}
+
+func Mode(orderBy ... interface{}) *FuncCallNode {
+	return FuncCall("mode").WithinGroup(orderBy...)
+}
+
+// Hypothetical-set aggregates (i.e. rank(42) WITHIN GROUP (ORDER BY x)); call WithinGroup on the result.
+
+func Rank(args ... interface{}) *FuncCallNode {
+	return FuncCall("rank", args...)
+}
+
+func DenseRank(args ... interface{}) *FuncCallNode {
+	return FuncCall("dense_rank", args...)
+}
+
+func PercentRank(args ... interface{}) *FuncCallNode {
+	return FuncCall("percent_rank", args...)
+}
+
+func CumeDist(args ... interface{}) *FuncCallNode {
+	return FuncCall("cume_dist", args...)
+}
@@ -1,9 +1,11 @@
 package pgqb
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"regexp"
+	"time"
 )
 
 // Node in the abstract syntax tree.
@@ -23,12 +25,22 @@ type TableExp interface {
 	astNode
 	isTableExp()
 	collectColSources(collector colSrcMap)
+	// Column sources referenced by the expressions inside the table expression (i.e. function
+	// arguments), which must precede it in the FROM clause.
+	collectRefColSources(collector colSrcMap)
+	// The node itself, i.e. the table of a model.
+	node() TableExp
 
 	Join(joinType JoinType, dst TableExp, onExp ColExp) TableExp
 	InnerJoin(dst TableExp, onExp ColExp) TableExp
 	LeftOuterJoin(dst TableExp, onExp ColExp) TableExp
 	RightOuterJoin(dst TableExp, onExp ColExp) TableExp
+	FullOuterJoin(dst TableExp, onExp ColExp) TableExp
+	CrossJoin(dst TableExp) TableExp
 	NaturalJoin(dst TableExp) TableExp
+	NaturalLeftJoin(dst TableExp) TableExp
+	NaturalRightJoin(dst TableExp) TableExp
+	JoinUsing(joinType JoinType, dst TableExp, cols ... *ColumnNode) TableExp
 }
 
 type BaseTableExpNode struct {
@@ -43,6 +55,12 @@ func (BaseTableExpNode) toSQL(ctx *buildContext) {
 
 func (BaseTableExpNode) collectColSources(collector colSrcMap) {}
 
+func (BaseTableExpNode) collectRefColSources(collector colSrcMap) {}
+
+func (n *BaseTableExpNode) node() TableExp {
+	return n.TableExp
+}
+
 func (n *BaseTableExpNode) Join(joinType JoinType, dst TableExp, onExp ColExp) TableExp {
 	return Join(joinType, n.TableExp, dst, onExp)
 }
@@ -59,10 +77,31 @@ func (n *BaseTableExpNode) RightOuterJoin(dst TableExp, onExp ColExp) TableExp {
 	return n.Join(RightOuterJoin, dst, onExp)
 }
 
+func (n *BaseTableExpNode) FullOuterJoin(dst TableExp, onExp ColExp) TableExp {
+	return n.Join(FullOuterJoin, dst, onExp)
+}
+
+func (n *BaseTableExpNode) CrossJoin(dst TableExp) TableExp {
+	return n.Join(CrossJoin, dst, nil)
+}
+
 func (n *BaseTableExpNode) NaturalJoin(dst TableExp) TableExp {
 	return n.Join(NaturalJoin, dst, nil)
 }
 
+func (n *BaseTableExpNode) NaturalLeftJoin(dst TableExp) TableExp {
+	return n.Join(NaturalLeftJoin, dst, nil)
+}
+
+func (n *BaseTableExpNode) NaturalRightJoin(dst TableExp) TableExp {
+	return n.Join(NaturalRightJoin, dst, nil)
+}
+
+// Join on the columns that share the same names in both table expressions.
+func (n *BaseTableExpNode) JoinUsing(joinType JoinType, dst TableExp, cols ... *ColumnNode) TableExp {
+	return JoinUsing(joinType, n.TableExp, dst, cols...)
+}
+
 func (BaseTableExpNode) isTableExp() {}
 
 // Table/View.
@@ -76,6 +115,16 @@ func (n *TableNode) name() string {
 	return n.tbname
 }
 
+// Schema of the table, or "" if not qualified.
+func (n *TableNode) Schema() string {
+	return n.schema
+}
+
+// Name of the table.
+func (n *TableNode) Name() string {
+	return n.tbname
+}
+
 func (n *TableNode) collectColSources(collector colSrcMap) {
 	id := n.name()
 	collector[id] = n
@@ -106,6 +155,11 @@ func Table(schema, tbname string) *TableNode {
 	return node
 }
 
+// Column sources that render their own alias (i.e. subqueries and table functions).
+type selfAliasedColSource interface {
+	isSelfAliased()
+}
+
 // Alias of a table/view.
 type TableAliasNode struct {
 	BaseTableExpNode
@@ -118,8 +172,17 @@ func (n *TableAliasNode) collectColSources(collector colSrcMap) {
 	collector[id] = n
 }
 
+func (n *TableAliasNode) collectRefColSources(collector colSrcMap) {
+	if tbExp, ok := n.table.(TableExp); ok {
+		tbExp.collectRefColSources(collector)
+	}
+}
+
 func (n *TableAliasNode) toSQL(ctx *buildContext) {
 	n.table.(astNode).toSQL(ctx)
+	if _, ok := n.table.(selfAliasedColSource); ok {
+		return
+	}
 	// TODO: This may be Postgres-specific.
 	ctx.buf.WriteString(" " + ctx.QuoteObject(n.alias))
 }
@@ -147,18 +210,32 @@ func TableAlias(table ColSource, alias string) *TableAliasNode {
 type JoinType string
 
 const (
-	InnerJoin      JoinType = "INNER JOIN"
-	LeftOuterJoin           = "LEFT OUTER JOIN"
-	RightOuterJoin          = "RIGHT OUTER JOIN"
-	NaturalJoin             = "NATURAL JOIN"
+	InnerJoin        JoinType = "INNER JOIN"
+	LeftOuterJoin             = "LEFT OUTER JOIN"
+	RightOuterJoin            = "RIGHT OUTER JOIN"
+	FullOuterJoin             = "FULL OUTER JOIN"
+	CrossJoin                 = "CROSS JOIN"
+	NaturalJoin               = "NATURAL JOIN"
+	NaturalLeftJoin           = "NATURAL LEFT OUTER JOIN"
+	NaturalRightJoin          = "NATURAL RIGHT OUTER JOIN"
 )
 
+// Whether the join type takes neither an ON nor a USING condition.
+func (t JoinType) isUnconditional() bool {
+	switch t {
+	case CrossJoin, NaturalJoin, NaturalLeftJoin, NaturalRightJoin:
+		return true
+	}
+	return false
+}
+
 type JoinNode struct {
 	BaseTableExpNode
-	src      TableExp
-	dst      TableExp
-	exp      ColExp
-	joinType JoinType
+	src       TableExp
+	dst       TableExp
+	exp       ColExp
+	usingCols []*ColumnNode
+	joinType  JoinType
 }
 
 func (JoinNode) isTableExp() {}
@@ -170,11 +247,33 @@ func (n *JoinNode) collectColSources(collector colSrcMap) {
 	n.dst.collectColSources(collector)
 }
 
+func (n *JoinNode) collectRefColSources(collector colSrcMap) {
+	n.src.collectRefColSources(collector)
+	n.dst.collectRefColSources(collector)
+}
+
 func (n *JoinNode) toSQL(ctx *buildContext) {
 	n.src.toSQL(ctx)
-	ctx.buf.WriteString(" " + string(n.joinType) + " ")
+	ctx.indent++
+	ctx.lineBreak()
+	ctx.buf.WriteString(string(n.joinType) + " ")
 	n.dst.toSQL(ctx)
-	if n.joinType != NaturalJoin {
+	ctx.indent--
+	if n.joinType.isUnconditional() {
+		return
+	}
+	if len(n.usingCols) > 0 {
+		origState := ctx.setState(buildContextStateNoColumnSource)
+		ctx.buf.WriteString(" USING (")
+		for i, col := range n.usingCols {
+			if i > 0 {
+				ctx.buf.WriteString(", ")
+			}
+			col.toSQL(ctx)
+		}
+		ctx.buf.WriteByte(')')
+		ctx.setState(origState)
+	} else {
 		ctx.buf.WriteString(" ON (")
 		n.exp.toSQL(ctx)
 		ctx.buf.WriteByte(')')
@@ -182,7 +281,50 @@ func (n *JoinNode) toSQL(ctx *buildContext) {
 }
 
 func Join(joinType JoinType, src, dst TableExp, onExp ColExp) *JoinNode {
-	return &JoinNode{src: src, exp: onExp, dst: dst, joinType: joinType}
+	node := &JoinNode{src: src, exp: onExp, dst: dst, joinType: joinType}
+	node.TableExp = node
+	return node
+}
+
+func JoinUsing(joinType JoinType, src, dst TableExp, cols ... *ColumnNode) *JoinNode {
+	if len(cols) == 0 {
+		panic("must have at least one column in USING")
+	}
+	if joinType.isUnconditional() {
+		panic(string(joinType) + " cannot have USING")
+	}
+	node := Join(joinType, src, dst, nil)
+	node.usingCols = cols
+	return node
+}
+
+// LATERAL table expression (i.e. a subquery that may refer to columns of the preceding FROM items).
+type LateralNode struct {
+	BaseTableExpNode
+	exp TableExp
+}
+
+func (n *LateralNode) collectColSources(collector colSrcMap) {
+	n.exp.collectColSources(collector)
+}
+
+func (n *LateralNode) collectRefColSources(collector colSrcMap) {
+	n.exp.collectRefColSources(collector)
+}
+
+func (n *LateralNode) toSQL(ctx *buildContext) {
+	ctx.buf.WriteString("LATERAL ")
+	// References to the preceding FROM items must not be pulled into the subquery.
+	origMode := ctx.mode
+	ctx.mode &= ^ContextModeAutoFrom
+	n.exp.toSQL(ctx)
+	ctx.mode = origMode
+}
+
+func Lateral(exp TableExp) *LateralNode {
+	node := &LateralNode{exp: exp}
+	node.TableExp = node
+	return node
 }
 
 // Abstract expression.
@@ -337,7 +479,7 @@ func (n *BaseColExpNode) In(right interface{}) ColExp {
 }
 
 func (n *BaseColExpNode) NotIn(right interface{}) ColExp {
-	return BinaryExp(n.ColExp, opIn, getExp(right))
+	return BinaryExp(n.ColExp, opNotIn, getExp(right))
 }
 
 func (n *BaseColExpNode) BitAnd(right interface{}) ColExp {
@@ -405,6 +547,8 @@ func SQL(sql string, args ... ColExp) *SQLNode {
 
 var Default = SQL("DEFAULT")
 
+var limitAll = SQL("ALL")
+
 // Placeholder for an argument.
 type ArgumentNode struct {
 	BaseColExpNode
@@ -412,13 +556,33 @@ type ArgumentNode struct {
 }
 
 func (n *ArgumentNode) toSQL(ctx *buildContext) {
+	if ctx.normalize {
+		ctx.buf.WriteString(normalizedValue)
+		return
+	}
+	if ctx.argTags {
+		if n.tag == "" {
+			// Copies of the statement would not recognize the assignment.
+			panic("untagged arguments are not allowed in assignment targets")
+		}
+		ctx.buf.WriteString(":" + n.tag)
+		return
+	}
 	if ctx.NamedArgumentMode() {
 		if n.tag == "" {
 			panic("empty tag opIs not allowed in NamedArgument mode")
 		}
+		if value, in := ctx.namedArgValues[n.tag]; in {
+			ctx.buf.WriteString(debugLiteral(value))
+			return
+		}
 		ctx.buf.WriteString(":" + n.tag)
 	} else {
 		argNum := ctx.getArgNum(n.tag)
+		if argNum <= len(ctx.argValues) {
+			ctx.buf.WriteString(debugLiteral(ctx.argValues[argNum-1]))
+			return
+		}
 		ctx.buf.WriteString("$" + strconv.FormatInt(int64(argNum), 10))
 	}
 }
@@ -473,7 +637,9 @@ func convertValueToLiteral(value interface{}) string {
 		res = strconv.FormatBool(value.(bool))
 	case string:
 		// TODO: This opIs Postgres-specific
-		res = "'" + value.(string) + "'"
+		res = quoteLiteral(value.(string))
+	case time.Time:
+		res = quoteLiteral(value.(time.Time).Format(timeLiteralLayout))
 	default:
 		if l, ok := value.(SQLLiteral); ok {
 			res = l.GetSQLRepr()
@@ -484,6 +650,14 @@ func convertValueToLiteral(value interface{}) string {
 	return res
 }
 
+// Timestamp with the time zone offset, as Postgres accepts it.
+const timeLiteralLayout = "2006-01-02 15:04:05.999999999Z07:00"
+
+// Quote a string literal (assuming standard_conforming_strings, the default since Postgres 9.1).
+func quoteLiteral(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
 type LiteralNode struct {
 	BaseColExpNode
 	value string
@@ -492,6 +666,11 @@ type LiteralNode struct {
 var Null = Literal(nil)
 
 func (n *LiteralNode) toSQL(ctx *buildContext) {
+	// NULL is kept, as it is usually part of the shape of the query (i.e. IS NULL).
+	if ctx.normalize && n.value != "NULL" {
+		ctx.buf.WriteString(normalizedValue)
+		return
+	}
 	ctx.buf.WriteString(n.value)
 }
 
@@ -508,6 +687,17 @@ func getExp(exp interface{}) ColExp {
 	return Literal(exp)
 }
 
+// Same as getExp, except that nil stays nil.
+func getOptionalExp(exp interface{}) ColExp {
+	if exp == nil {
+		return nil
+	}
+	if colExp, ok := exp.(ColExp); ok && isNull(colExp) {
+		return nil
+	}
+	return getExp(exp)
+}
+
 // Array.
 type ArrayNode struct {
 	BaseColExpNode
@@ -515,6 +705,10 @@ type ArrayNode struct {
 }
 
 func (n *ArrayNode) toSQL(ctx *buildContext) {
+	if ctx.normalize {
+		ctx.buf.WriteString(normalizedValue)
+		return
+	}
 	if len(n.values) > 0 {
 		ctx.buf.WriteString("ARRAY[")
 		for i, value := range n.values {
@@ -550,6 +744,9 @@ func (n *TupleNode) toSQL(ctx *buildContext) {
 		if i > 0 {
 			ctx.buf.WriteString(", ")
 		}
+		if ctx.normalize {
+			value = normalizedValue
+		}
 		ctx.buf.WriteString(value)
 	}
 	ctx.buf.WriteByte(')')
@@ -581,6 +778,11 @@ type ColumnNode struct {
 	name string
 }
 
+// Name of the column.
+func (n *ColumnNode) Name() string {
+	return n.name
+}
+
 func (n *ColumnNode) toSQL(ctx *buildContext) {
 	if ctx.state != buildContextStateNoColumnSource {
 		ctx.buf.WriteString(ctx.QuoteObject(n.source.name()) + ".")
@@ -588,6 +790,8 @@ func (n *ColumnNode) toSQL(ctx *buildContext) {
 	ctx.buf.WriteString(ctx.QuoteObject(n.name))
 }
 
+func (ColumnNode) isSetTarget() {}
+
 func Column(src ColSource, cname string) *ColumnNode {
 	node := &ColumnNode{name: cname}
 	node.source = src
@@ -595,6 +799,134 @@ func Column(src ColSource, cname string) *ColumnNode {
 	return node
 }
 
+// Parenthesized column list, used as the target of a multi-column assignment (i.e. SET (a, b) = (x, y)).
+type ColumnListNode struct {
+	BaseColExpNode
+	cols []*ColumnNode
+}
+
+func (ColumnListNode) isSetTarget() {}
+
+func (n *ColumnListNode) toSQL(ctx *buildContext) {
+	ctx.buf.WriteByte('(')
+	for i, col := range n.cols {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		col.toSQL(ctx)
+	}
+	ctx.buf.WriteByte(')')
+}
+
+func (n *ColumnListNode) collectColSources(collector colSrcMap) {
+	for _, col := range n.cols {
+		col.collectColSources(collector)
+	}
+}
+
+func Columns(cols ... *ColumnNode) *ColumnListNode {
+	if len(cols) == 0 {
+		panic("must have at least one column")
+	}
+	node := &ColumnListNode{cols: cols}
+	node.ColExp = node
+	return node
+}
+
+// Array element / slice or jsonb field (i.e. "col"[1], "col"['key']).
+type SubscriptNode struct {
+	BaseColExpNode
+	exp   ColExp
+	index ColExp
+	upper ColExp
+}
+
+func (SubscriptNode) isSetTarget() {}
+
+func (n *SubscriptNode) toSQL(ctx *buildContext) {
+	switch n.exp.(type) {
+	case *ColumnNode, *SubscriptNode, *FuncCallNode:
+		n.exp.toSQL(ctx)
+	default:
+		ctx.buf.WriteByte('(')
+		n.exp.toSQL(ctx)
+		ctx.buf.WriteByte(')')
+	}
+	ctx.buf.WriteByte('[')
+	n.index.toSQL(ctx)
+	if n.upper != nil {
+		ctx.buf.WriteByte(':')
+		n.upper.toSQL(ctx)
+	}
+	ctx.buf.WriteByte(']')
+}
+
+func (n *SubscriptNode) collectColSources(collector colSrcMap) {
+	n.exp.collectColSources(collector)
+	n.index.collectColSources(collector)
+	if n.upper != nil {
+		n.upper.collectColSources(collector)
+	}
+}
+
+func Subscript(exp ColExp, index interface{}) *SubscriptNode {
+	node := &SubscriptNode{exp: exp, index: getExp(index)}
+	node.ColExp = node
+	return node
+}
+
+// Array slice (i.e. "col"[2:3]).
+func Slice(exp ColExp, lower, upper interface{}) *SubscriptNode {
+	node := Subscript(exp, lower)
+	node.upper = getExp(upper)
+	return node
+}
+
+// Row constructor (i.e. ("a", "b"), ROW(1)).
+type RowNode struct {
+	MultiExpNode
+}
+
+func (n *RowNode) toSQL(ctx *buildContext) {
+	if len(n.expList) < 2 {
+		ctx.buf.WriteString("ROW")
+	}
+	ctx.buf.WriteByte('(')
+	for i, exp := range n.expList {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		exp.toSQL(ctx)
+	}
+	ctx.buf.WriteByte(')')
+}
+
+func Row(exps ... interface{}) *RowNode {
+	node := &RowNode{MultiExpNode: *MultiExp(getExpList(exps))}
+	node.ColExp = node
+	return node
+}
+
+// Row proposed for insertion in ON CONFLICT DO UPDATE.
+type excludedColSource struct{}
+
+func (excludedColSource) name() string {
+	return "excluded"
+}
+
+func (excludedColSource) As(alias string) *TableAliasNode {
+	panic("invalid operation")
+}
+
+func (s excludedColSource) Column(cname string) *ColumnNode {
+	return Column(s, cname)
+}
+
+// Refer to the value proposed for insertion (i.e. SET col = EXCLUDED.col).
+func Excluded(col *ColumnNode) *ColumnNode {
+	return excludedColSource{}.Column(col.name)
+}
+
 // Alias of ColExp.
 type ColExpAliasNode struct {
 	BaseColExpNode
@@ -667,20 +999,9 @@ func G(exp ColExp) *GroupExpNode {
 	return Group(exp)
 }
 
-// Compound expressions
-type compoundExp interface {
-	isCompoundExp()
-}
-
+// Render the expression, surrounding it with parentheses unless it is atomic.
 func compoundExpToSQL(exp ColExp, ctx *buildContext) {
-	_, isCompound := exp.(compoundExp)
-	if isCompound {
-		ctx.buf.WriteByte('(')
-		exp.toSQL(ctx)
-		ctx.buf.WriteByte(')')
-	} else {
-		exp.toSQL(ctx)
-	}
+	operandToSQL(exp, precAtom, ctx)
 }
 
 // Unary expressions
@@ -699,8 +1020,6 @@ type UnaryExpNode struct {
 	spaceSep bool
 }
 
-func (UnaryExpNode) isCompoundExp() {}
-
 func (n *UnaryExpNode) collectColSources(collector colSrcMap) {
 	n.exp.collectColSources(collector)
 }
@@ -711,7 +1030,22 @@ func (n *UnaryExpNode) toSQL(ctx *buildContext) {
 		if n.spaceSep {
 			ctx.buf.WriteByte(' ')
 		}
-		compoundExpToSQL(n.exp, ctx)
+		if n.op == opNot {
+			operandToSQL(n.exp, precNot, ctx)
+		} else {
+			// Other prefix operators bind less tightly than they look (i.e. @ a + b is @ (a + b)).
+			start := ctx.buf.Len()
+			compoundExpToSQL(n.exp, ctx)
+			if !n.spaceSep && isOperator(n.op) && ctx.buf.Len() > start &&
+				strings.IndexByte(operatorChars, ctx.buf.Bytes()[start]) >= 0 {
+				// Separate an operand starting with an operator (i.e. -5), which would otherwise be read as part
+				// of the operator (i.e. @-5) or start a comment (i.e. --5).
+				operand := append([]byte{}, ctx.buf.Bytes()[start:]...)
+				ctx.buf.Truncate(start)
+				ctx.buf.WriteByte(' ')
+				ctx.buf.Write(operand)
+			}
+		}
 	} else {
 		compoundExpToSQL(n.exp, ctx)
 		if n.spaceSep {
@@ -780,21 +1114,54 @@ const (
 )
 
 func Not(exp interface{}) *UnaryExpNode {
-	return UnaryExp(Group(getExp(exp)), opNot, posLeft)
+	return UnaryExp(getExp(exp), opNot, posLeft)
 }
 
 // Order expression
 type OrderExpNode struct {
 	UnaryExpNode
+	nulls string
 }
 
 func (OrderExpNode) As(alias string) ColExp {
 	panic("invalid operation")
 }
 
+func (n *OrderExpNode) toSQL(ctx *buildContext) {
+	// Besides COLLATE, operators are surrounded, as CREATE INDEX requires parentheses around expressions.
+	operandToSQL(n.exp, precCollate, ctx)
+	ctx.buf.WriteString(" " + n.op)
+	if n.nulls != "" {
+		ctx.buf.WriteString(" NULLS " + n.nulls)
+	}
+}
+
+// Sort the NULL values before the non-NULL ones.
+func (n *OrderExpNode) NullsFirst() *OrderExpNode {
+	n.nulls = nullsFirst
+	return n
+}
+
+// Sort the NULL values after the non-NULL ones.
+func (n *OrderExpNode) NullsLast() *OrderExpNode {
+	n.nulls = nullsLast
+	return n
+}
+
+// Sort using the given collation (i.e. "C").
+func (n *OrderExpNode) Collate(collation string) *OrderExpNode {
+	n.exp = Collate(n.exp, collation)
+	return n
+}
+
 const (
 	orderAsc  string = "ASC"
 	orderDesc string = "DESC"
+
+	orderUsing string = "USING "
+
+	nullsFirst string = "FIRST"
+	nullsLast  string = "LAST"
 )
 
 func OrderExp(exp interface{}, order string) *OrderExpNode {
@@ -811,6 +1178,36 @@ func Desc(exp interface{}) *OrderExpNode {
 	return OrderExp(exp, orderDesc)
 }
 
+// Sort by the given less-than or greater-than operator (i.e. ORDER BY "a" USING <).
+func OrderUsing(exp interface{}, op string) *OrderExpNode {
+	if !isOperator(op) {
+		panic(fmt.Sprintf("invalid sort operator %q", op))
+	}
+	return OrderExp(exp, orderUsing+op)
+}
+
+// Expression with an explicit collation (i.e. "name" COLLATE "C").
+type CollateNode struct {
+	BaseColExpNode
+	exp       ColExp
+	collation string
+}
+
+func (n *CollateNode) toSQL(ctx *buildContext) {
+	operandToSQL(n.exp, precCollate, ctx)
+	ctx.buf.WriteString(" COLLATE " + ctx.QuoteObject(n.collation))
+}
+
+func (n *CollateNode) collectColSources(collector colSrcMap) {
+	n.exp.collectColSources(collector)
+}
+
+func Collate(exp interface{}, collation string) *CollateNode {
+	node := &CollateNode{exp: getExp(exp), collation: collation}
+	node.ColExp = node
+	return node
+}
+
 // Binary expressions
 type BinaryExpNode struct {
 	BaseColExpNode
@@ -819,12 +1216,23 @@ type BinaryExpNode struct {
 	op    string
 }
 
-func (BinaryExpNode) isCompoundExp() {}
-
 func (n *BinaryExpNode) toSQL(ctx *buildContext) {
-	compoundExpToSQL(n.left, ctx)
+	// Operators are left-associative, so only the left operand may have the same precedence.
+	prec := precedence(n)
+	leftPrec, rightPrec := prec, prec+1
+	if prec == precUnknown {
+		leftPrec, rightPrec = precAtom, precAtom
+	} else if isNonAssociative(prec) {
+		leftPrec++
+	}
+	operandToSQL(n.left, leftPrec, ctx)
 	ctx.buf.WriteString(" " + n.op + " ")
-	compoundExpToSQL(n.right, ctx)
+	if ctx.normalize && (n.op == opIn || n.op == opNotIn) && isValueList(n.right) {
+		// Lists of different lengths have the same shape.
+		ctx.buf.WriteString("(" + normalizedValue + ")")
+		return
+	}
+	operandToSQL(n.right, rightPrec, ctx)
 }
 
 func (n *BinaryExpNode) collectColSources(collector colSrcMap) {
@@ -903,43 +1311,32 @@ func MultiExp(expList []ColExp) *MultiExpNode {
 }
 
 // Logical operations.
-type logicalExp interface {
-	isLogicalExp()
-}
-
 type LogicalExpNode struct {
 	MultiExpNode
 	op string
 }
 
-func logicalExpToSQL(exp ColExp, ctx *buildContext) {
-	_, isLogical := exp.(logicalExp)
-	if isLogical {
-		ctx.buf.WriteByte('(')
-		exp.toSQL(ctx)
-		ctx.buf.WriteByte(')')
-	} else {
-		exp.toSQL(ctx)
-	}
-}
-
-func (LogicalExpNode) isLogicalExp() {}
-
-func (LogicalExpNode) isCompoundExp() {}
-
 func (n *LogicalExpNode) toSQL(ctx *buildContext) {
+	if len(n.expList) == 0 {
+		// Identity element of the operator.
+		if n.op == opOr {
+			ctx.buf.WriteString("FALSE")
+		} else {
+			ctx.buf.WriteString("TRUE")
+		}
+		return
+	}
 	for i, exp := range n.expList {
 		if i > 0 {
 			ctx.buf.WriteString(" " + n.op + " ")
 		}
-		logicalExpToSQL(exp, ctx)
+		// AND and OR are associative.
+		operandToSQL(exp, precedence(n), ctx)
 	}
 }
 
+// An empty expression list renders TRUE for AND and FALSE for OR.
 func LogicalExp(op string, expList []ColExp) *LogicalExpNode {
-	if len(expList) == 0 {
-		panic("must have at least one sub-expression")
-	}
 	node := &LogicalExpNode{MultiExpNode: *MultiExp(expList), op: op}
 	node.ColExp = node
 	return node
@@ -958,30 +1355,132 @@ func getExpList(exps []interface{}) []ColExp {
 	return expList
 }
 
+// Whether the expression can be left out of a logical expression with the given operator (i.e. nil or an empty
+// expression with the same operator).
+func isIdentityExp(op string, exp interface{}) bool {
+	if exp == nil {
+		return true
+	}
+	if colExp, ok := exp.(ColExp); ok && isNull(colExp) {
+		return true
+	}
+	logical, ok := exp.(*LogicalExpNode)
+	return ok && logical.op == op && len(logical.expList) == 0
+}
+
+// Same as getExpList, except that the identity expressions of the operator are left out.
+func getLogicalExpList(op string, exps []interface{}) []ColExp {
+	var expList = make([]ColExp, 0, len(exps))
+	for _, exp := range exps {
+		if !isIdentityExp(op, exp) {
+			expList = append(expList, getExp(exp))
+		}
+	}
+	return expList
+}
+
+// Nil entries are ignored; And() is TRUE.
 func And(exps ... interface{}) *LogicalExpNode {
-	return LogicalExp(opAnd, getExpList(exps))
+	return LogicalExp(opAnd, getLogicalExpList(opAnd, exps))
 }
 
+// Nil entries are ignored; Or() is FALSE.
 func Or(exps ... interface{}) *LogicalExpNode {
-	return LogicalExp(opOr, getExpList(exps))
+	return LogicalExp(opOr, getLogicalExpList(opOr, exps))
 }
 
 // Function call expression.
 type FuncCallNode struct {
 	MultiExpNode
 	name string
+	// Aggregate modifiers.
+	distinct    bool
+	orderBy     *orderByClause
+	withinGroup *orderByClause
+	filter      *whereClause
 }
 
 func (n *FuncCallNode) toSQL(ctx *buildContext) {
 	ctx.buf.WriteString(n.name)
 	ctx.buf.WriteByte('(')
+	if n.distinct {
+		ctx.buf.WriteString("DISTINCT ")
+	}
 	for i, exp := range n.expList {
 		if i > 0 {
 			ctx.buf.WriteString(", ")
 		}
 		exp.toSQL(ctx)
 	}
+	if n.orderBy != nil {
+		ctx.buf.WriteString(" ORDER BY ")
+		n.orderBy.baseColExpListClause.toSQL(ctx)
+	}
 	ctx.buf.WriteByte(')')
+	if n.withinGroup != nil {
+		ctx.buf.WriteString(" WITHIN GROUP (ORDER BY ")
+		n.withinGroup.baseColExpListClause.toSQL(ctx)
+		ctx.buf.WriteByte(')')
+	}
+	if n.filter != nil && !isNull(n.filter.predicate) {
+		ctx.buf.WriteString(" FILTER (WHERE ")
+		n.filter.basePredicateClause.toSQL(ctx)
+		ctx.buf.WriteByte(')')
+	}
+}
+
+func (n *FuncCallNode) collectColSources(collector colSrcMap) {
+	n.MultiExpNode.collectColSources(collector)
+	for _, c := range []clause{n.orderBy, n.withinGroup, n.filter} {
+		if !isNull(c) {
+			c.collectColSources(collector)
+		}
+	}
+}
+
+// Aggregate over distinct input values only (i.e. count(DISTINCT x)).
+func (n *FuncCallNode) Distinct() *FuncCallNode {
+	if len(n.expList) == 0 {
+		panic("DISTINCT requires at least one argument")
+	}
+	n.distinct = true
+	return n
+}
+
+// Order the input rows of the aggregate (i.e. string_agg(x, ',' ORDER BY y)).
+func (n *FuncCallNode) OrderBy(exps ... interface{}) *FuncCallNode {
+	if len(exps) == 0 {
+		return n
+	}
+	if n.orderBy == nil {
+		n.orderBy = &orderByClause{}
+	}
+	n.orderBy.addColExp(exps...)
+	return n
+}
+
+// Sort order of an ordered-set aggregate (i.e. percentile_cont(0.9) WITHIN GROUP (ORDER BY x)).
+func (n *FuncCallNode) WithinGroup(exps ... interface{}) *FuncCallNode {
+	if len(exps) == 0 {
+		return n
+	}
+	if n.withinGroup == nil {
+		n.withinGroup = &orderByClause{}
+	}
+	n.withinGroup.addColExp(exps...)
+	return n
+}
+
+// Only feed the rows satisfying all the predicates to the aggregate.
+func (n *FuncCallNode) Filter(exps ... interface{}) *FuncCallNode {
+	if len(exps) == 0 {
+		return n
+	}
+	if n.filter == nil {
+		n.filter = &whereClause{}
+	}
+	n.filter.addPredicate(exps...)
+	return n
 }
 
 func FuncCall(name string, args ... interface{}) *FuncCallNode {
@@ -1000,6 +1499,81 @@ func CreateFuncCallFactory(name string) FuncCallFactory {
 	}
 }
 
+// Grouping sets in GROUP BY (i.e. ROLLUP, CUBE, GROUPING SETS).
+type GroupingSetsNode struct {
+	BaseColExpNode
+	op   string
+	sets [][]ColExp
+}
+
+func (n *GroupingSetsNode) toSQL(ctx *buildContext) {
+	ctx.buf.WriteString(n.op + " (")
+	for i, set := range n.sets {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		if len(set) == 1 {
+			set[0].toSQL(ctx)
+			continue
+		}
+		ctx.buf.WriteByte('(')
+		for j, exp := range set {
+			if j > 0 {
+				ctx.buf.WriteString(", ")
+			}
+			exp.toSQL(ctx)
+		}
+		ctx.buf.WriteByte(')')
+	}
+	ctx.buf.WriteByte(')')
+}
+
+func (n *GroupingSetsNode) collectColSources(collector colSrcMap) {
+	for _, set := range n.sets {
+		for _, exp := range set {
+			exp.collectColSources(collector)
+		}
+	}
+}
+
+// Each element is either a single expression or a []interface{} of expressions grouped together.
+func GroupingSetsExp(op string, sets []interface{}) *GroupingSetsNode {
+	node := &GroupingSetsNode{op: op, sets: make([][]ColExp, len(sets))}
+	for i, set := range sets {
+		if exps, ok := set.([]interface{}); ok {
+			node.sets[i] = getExpList(exps)
+		} else {
+			node.sets[i] = []ColExp{getExp(set)}
+		}
+	}
+	node.ColExp = node
+	return node
+}
+
+const (
+	opRollup       string = "ROLLUP"
+	opCube                = "CUBE"
+	opGroupingSets        = "GROUPING SETS"
+)
+
+func Rollup(sets ... interface{}) *GroupingSetsNode {
+	return GroupingSetsExp(opRollup, sets)
+}
+
+func Cube(sets ... interface{}) *GroupingSetsNode {
+	return GroupingSetsExp(opCube, sets)
+}
+
+// Pass an empty []interface{} for the grand total set (i.e. ()).
+func GroupingSets(sets ... interface{}) *GroupingSetsNode {
+	return GroupingSetsExp(opGroupingSets, sets)
+}
+
+// GROUPING(...): bit mask of the arguments not included in the current grouping set.
+func Grouping(exps ... interface{}) *FuncCallNode {
+	return FuncCall("GROUPING", exps...)
+}
+
 // Expressions that involve sub-queries (i.e. EXISTS, ALL, SOME).
 // TODO: Add tests.
 type SubQueryColExpNode struct {
@@ -1009,18 +1583,24 @@ type SubQueryColExpNode struct {
 }
 
 func (n *SubQueryColExpNode) toSQL(ctx *buildContext) {
-	ctx.buf.WriteString(n.op + " (")
+	if n.op != "" {
+		ctx.buf.WriteString(n.op + " ")
+	}
 	origMode := ctx.mode
 	ctx.mode &= ^ContextModeAutoFrom
-	n.selectStmt.toSQL(ctx)
+	ctx.parenthesizedStmtToSQL(n.selectStmt)
 	ctx.mode = origMode
-	ctx.buf.WriteByte(')')
 }
 
 func (n *SubQueryColExpNode) collectColSources(collector colSrcMap) {
 	// TODO: Update this list as we add more clauses to SELECT stmt.
 	usedSrcMap := collectColSourcesFromClauses(n.selectStmt.whereClause, n.selectStmt.selectClause)
 	fromSrcMap := collectColSourcesFromClauses(n.selectStmt.fromClause)
+	if n.selectStmt.fromClause != nil {
+		for name, colSrc := range n.selectStmt.fromClause.refColSources() {
+			usedSrcMap[name] = colSrc
+		}
+	}
 	difference := usedSrcMap.Subtract(fromSrcMap)
 	// Include all the column sources not specified in the subquery
 	for _, colSrc := range difference {
@@ -1041,6 +1621,11 @@ const (
 	// TODO: More operators
 )
 
+// Scalar or row subquery (i.e. SET (a, b) = (SELECT ...)).
+func SubQuery(stmt *SelectStmt) *SubQueryColExpNode {
+	return SubQueryExp("", stmt)
+}
+
 func Exists(stmt *SelectStmt) *SubQueryColExpNode {
 	return SubQueryExp(opExists, stmt)
 }
@@ -1060,6 +1645,8 @@ type SubQueryTableExpNode struct {
 	selectStmt *SelectStmt
 }
 
+func (SubQueryTableExpNode) isSelfAliased() {}
+
 func (n *SubQueryTableExpNode) name() string {
 	return n.alias
 }
@@ -1072,10 +1659,13 @@ func (n *SubQueryTableExpNode) Column(cname string) *ColumnNode {
 	return Column(n, cname)
 }
 
+func (n *SubQueryTableExpNode) collectColSources(collector colSrcMap) {
+	collector[n.name()] = n
+}
+
 func (n *SubQueryTableExpNode) toSQL(ctx *buildContext) {
-	ctx.buf.WriteByte('(')
-	n.selectStmt.toSQL(ctx)
-	ctx.buf.WriteString(") " + ctx.QuoteObject(n.alias))
+	ctx.parenthesizedStmtToSQL(n.selectStmt)
+	ctx.buf.WriteString(" " + ctx.QuoteObject(n.alias))
 }
 
 func SubQueryTableExp(stmt *SelectStmt, alias string) *SubQueryTableExpNode {
@@ -1084,7 +1674,189 @@ func SubQueryTableExp(stmt *SelectStmt, alias string) *SubQueryTableExpNode {
 	return node
 }
 
-// TODO: Array accessor (i.e. '{2, 7, 3}'[1]).
+// VALUES list used as a table expression (i.e. (VALUES (1, 'a'), (2, 'b')) "v"("id", "name")).
+type ValuesTableExpNode struct {
+	BaseTableExpNode
+	alias      string
+	colNames   []string
+	valuesList [][]ColExp
+}
+
+func (ValuesTableExpNode) isSelfAliased() {}
+
+func (n *ValuesTableExpNode) name() string {
+	return n.alias
+}
+
+func (n *ValuesTableExpNode) As(alias string) *TableAliasNode {
+	node := ValuesTable(alias, n.colNames...)
+	node.valuesList = append(node.valuesList, n.valuesList...)
+	return TableAlias(node, alias)
+}
+
+func (n *ValuesTableExpNode) Column(cname string) *ColumnNode {
+	return Column(n, cname)
+}
+
+func (n *ValuesTableExpNode) collectColSources(collector colSrcMap) {
+	collector[n.name()] = n
+}
+
+func (n *ValuesTableExpNode) collectRefColSources(collector colSrcMap) {
+	for _, row := range n.valuesList {
+		for _, exp := range row {
+			exp.collectColSources(collector)
+		}
+	}
+}
+
+func (n *ValuesTableExpNode) toSQL(ctx *buildContext) {
+	if len(n.valuesList) == 0 {
+		panic("must have at least one row of values")
+	}
+	ctx.buf.WriteByte('(')
+	valuesListToSQL(n.valuesList, ctx)
+	ctx.buf.WriteString(") " + ctx.QuoteObject(n.alias))
+	if len(n.colNames) > 0 {
+		ctx.buf.WriteByte('(')
+		for i, cname := range n.colNames {
+			if i > 0 {
+				ctx.buf.WriteString(", ")
+			}
+			ctx.buf.WriteString(ctx.QuoteObject(cname))
+		}
+		ctx.buf.WriteByte(')')
+	}
+}
+
+// Append a row of values.
+func (n *ValuesTableExpNode) Values(exps ... interface{}) *ValuesTableExpNode {
+	n.valuesList = append(n.valuesList, getExpList(exps))
+	return n
+}
+
+func (n *ValuesTableExpNode) ValuesInBulk(tuples ... []interface{}) *ValuesTableExpNode {
+	for _, tuple := range tuples {
+		n.valuesList = append(n.valuesList, getExpList(tuple))
+	}
+	return n
+}
+
+func ValuesTable(alias string, cnames ... string) *ValuesTableExpNode {
+	node := &ValuesTableExpNode{alias: alias, colNames: append([]string{}, cnames...)}
+	node.TableExp = node
+	return node
+}
+
+// Set-returning function(s) used as a table expression (i.e. generate_series, unnest).
+type TableFuncNode struct {
+	BaseTableExpNode
+	funcs      []*FuncCallNode
+	alias      string
+	ordinality bool
+	// Column alias list; a non-empty type turns it into a column definition list.
+	colNames []string
+	colTypes []string
+}
+
+func (TableFuncNode) isSelfAliased() {}
+
+func (n *TableFuncNode) name() string {
+	return n.alias
+}
+
+func (n *TableFuncNode) As(alias string) *TableAliasNode {
+	node := *n
+	node.alias = alias
+	node.colNames = append([]string{}, n.colNames...)
+	node.colTypes = append([]string{}, n.colTypes...)
+	node.TableExp = &node
+	return TableAlias(&node, alias)
+}
+
+func (n *TableFuncNode) Column(cname string) *ColumnNode {
+	return Column(n, cname)
+}
+
+func (n *TableFuncNode) collectColSources(collector colSrcMap) {
+	collector[n.name()] = n
+}
+
+func (n *TableFuncNode) collectRefColSources(collector colSrcMap) {
+	for _, fn := range n.funcs {
+		fn.collectColSources(collector)
+	}
+}
+
+func (n *TableFuncNode) toSQL(ctx *buildContext) {
+	if len(n.funcs) > 1 {
+		ctx.buf.WriteString("ROWS FROM (")
+	}
+	for i, fn := range n.funcs {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		fn.toSQL(ctx)
+	}
+	if len(n.funcs) > 1 {
+		ctx.buf.WriteByte(')')
+	}
+	if n.ordinality {
+		ctx.buf.WriteString(" WITH ORDINALITY")
+	}
+	ctx.buf.WriteString(" " + ctx.QuoteObject(n.alias))
+	if len(n.colNames) > 0 {
+		ctx.buf.WriteByte('(')
+		for i, cname := range n.colNames {
+			if i > 0 {
+				ctx.buf.WriteString(", ")
+			}
+			ctx.buf.WriteString(ctx.QuoteObject(cname))
+			if n.colTypes[i] != "" {
+				ctx.buf.WriteString(" " + n.colTypes[i])
+			}
+		}
+		ctx.buf.WriteByte(')')
+	}
+}
+
+// Append a bigint column numbering the rows returned (WITH ORDINALITY).
+func (n *TableFuncNode) WithOrdinality() *TableFuncNode {
+	n.ordinality = true
+	return n
+}
+
+// Name the output columns of the function(s).
+func (n *TableFuncNode) ColumnAliases(cnames ... string) *TableFuncNode {
+	for _, cname := range cnames {
+		n.colNames = append(n.colNames, cname)
+		n.colTypes = append(n.colTypes, "")
+	}
+	return n
+}
+
+// Declare an output column with its data type (i.e. for functions returning record).
+func (n *TableFuncNode) DefineColumn(cname, dataType string) *TableFuncNode {
+	n.colNames = append(n.colNames, cname)
+	n.colTypes = append(n.colTypes, dataType)
+	return n
+}
+
+func TableFunc(fn *FuncCallNode, alias string) *TableFuncNode {
+	node := &TableFuncNode{funcs: []*FuncCallNode{fn}, alias: alias}
+	node.TableExp = node
+	return node
+}
+
+// ROWS FROM (...) that zips the results of multiple functions.
+func RowsFrom(alias string, fns ... *FuncCallNode) *TableFuncNode {
+	if len(fns) == 0 {
+		panic("must have at least one function")
+	}
+	node := &TableFuncNode{funcs: fns, alias: alias}
+	node.TableExp = node
+	return node
+}
 
 // TODO: Nested arrays.
 
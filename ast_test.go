@@ -47,6 +47,69 @@ func TestTableNode_InnerJoin(t *testing.T) {
 		AstToSQL(tb.NaturalJoin(tb2)))
 }
 
+func TestJoinNode(t *testing.T) {
+	tb := Table("public", "tb")
+	tb2 := Table("public", "tb2")
+	tb3 := Table("public", "tb3")
+	assert.Equal(t, `"public"."tb" FULL OUTER JOIN "public"."tb2" ON ("tb"."id" = "tb2"."id")`,
+		AstToSQL(tb.FullOuterJoin(tb2, tb.Column("id").Eq(tb2.Column("id")))))
+	assert.Equal(t, `"public"."tb" CROSS JOIN "public"."tb2"`, AstToSQL(tb.CrossJoin(tb2)))
+	assert.Equal(t, `"public"."tb" NATURAL LEFT OUTER JOIN "public"."tb2"`, AstToSQL(tb.NaturalLeftJoin(tb2)))
+	assert.Equal(t, `"public"."tb" INNER JOIN "public"."tb2" USING ("id", "name")`,
+		AstToSQL(tb.JoinUsing(InnerJoin, tb2, tb.Column("id"), tb.Column("name"))))
+	assert.Equal(t, `"public"."tb" INNER JOIN "public"."tb2" USING ("id") LEFT OUTER JOIN "public"."tb3" ON ("tb3"."id" = "tb"."id")`,
+		AstToSQL(tb.JoinUsing(InnerJoin, tb2, tb.Column("id")).LeftOuterJoin(tb3, tb3.Column("id").Eq(tb.Column("id")))))
+	assert.Panics(t, func() {
+		tb.JoinUsing(InnerJoin, tb2)
+	})
+	assert.Panics(t, func() {
+		tb.JoinUsing(CrossJoin, tb2, tb.Column("id"))
+	})
+	assert.Panics(t, func() {
+		tb.JoinUsing(NaturalLeftJoin, tb2, tb.Column("id"))
+	})
+}
+
+func TestLateral(t *testing.T) {
+	tb := Table("public", "tb")
+	tb2 := Table("public", "tb2")
+	sub := SubQueryTableExp(Select(Star(tb2)).From(tb2).Where(tb2.Column("tbId").Eq(tb.Column("id"))).Limit(3), "top")
+	assert.Equal(t, `"public"."tb" CROSS JOIN LATERAL (SELECT "tb2".* FROM "public"."tb2" WHERE "tb2"."tbId" = "tb"."id" LIMIT 3 ) "top"`,
+		AstToSQL(tb.CrossJoin(Lateral(sub))))
+}
+
+func TestValuesTable(t *testing.T) {
+	v := ValuesTable("v", "id", "name").Values(1, "a").ValuesInBulk([]interface{}{Arg("id"), Arg("name")})
+	assert.Equal(t, `(VALUES (1, 'a'), ($1, $2)) "v"("id", "name")`, AstToSQL(v))
+	assert.Equal(t, `(VALUES (1, 'a'), ($1, $2)) "w"("id", "name")`, AstToSQL(v.As("w")))
+	assert.Equal(t, `"v"."name"`, AstToSQL(v.Column("name")))
+	assert.Panics(t, func() {
+		AstToSQL(ValuesTable("v"))
+	})
+	// The column names are copied.
+	cnames := []string{"id", "name"}
+	v = ValuesTable("v", cnames...).Values(1, "a")
+	cnames[0] = "x"
+	assert.Equal(t, `(VALUES (1, 'a')) "v"("id", "name")`, AstToSQL(v))
+}
+
+func TestTableFunc(t *testing.T) {
+	unnest := CreateFuncCallFactory("unnest")
+	fn := TableFunc(FuncCall("generate_series", 1, 10), "g")
+	assert.Equal(t, `generate_series(1, 10) "g"`, AstToSQL(fn))
+	assert.Equal(t, `"g"."g"`, AstToSQL(fn.Column("g")))
+
+	fn = TableFunc(unnest(SQL("?::int[]", Arg("ids"))), "t").WithOrdinality().ColumnAliases("id", "ord")
+	assert.Equal(t, `unnest($1::int[]) WITH ORDINALITY "t"("id", "ord")`, AstToSQL(fn))
+	assert.Equal(t, `unnest($1::int[]) WITH ORDINALITY "u"("id", "ord")`, AstToSQL(fn.As("u")))
+
+	fn = TableFunc(FuncCall("jsonb_to_recordset", Arg("rows")), "r").DefineColumn("a", "int").DefineColumn("b", "text")
+	assert.Equal(t, `jsonb_to_recordset($1) "r"("a" int, "b" text)`, AstToSQL(fn))
+
+	fn = RowsFrom("t", unnest(Array(1, 2)), unnest(Array("a", "b"))).ColumnAliases("num", "letter")
+	assert.Equal(t, `ROWS FROM (unnest(ARRAY[1, 2]), unnest(ARRAY['a', 'b'])) "t"("num", "letter")`, AstToSQL(fn))
+}
+
 func TestTableNode_As(t *testing.T) {
 	tb := Table("public", "tb")
 	assert.Equal(t, `"public"."tb" "newTb"`, AstToSQL(tb.As("newTb")))
@@ -76,6 +139,7 @@ func TestLiteral_Float(t *testing.T) {
 func TestLiteral_String(t *testing.T) {
 	l := Literal("22")
 	assert.Equal(t, "'22'", AstToSQL(l))
+	assert.Equal(t, "'O''Fallon'", AstToSQL(Literal("O'Fallon")))
 }
 
 func TestLiteral_Bool(t *testing.T) {
@@ -100,6 +164,9 @@ func TestColumn(t *testing.T) {
 
 	col = Column(myTb.As("NewTb"), "Col")
 	assert.Equal(t, `"NewTb"."Col"`, AstToSQL(col))
+
+	col = Column(myTb.As(`New"Tb`), `"; DROP TABLE t; --`)
+	assert.Equal(t, `"New""Tb"."""; DROP TABLE t; --"`, AstToSQL(col))
 }
 
 func TestColumnAlias(t *testing.T) {
@@ -113,9 +180,51 @@ func TestColumnAlias(t *testing.T) {
 func TestBaseColExpNode_Add(t *testing.T) {
 	x := Literal(2)
 	y := Literal(50)
-	assert.Equal(t, "(2 + 50) + 50", AstToSQL(x.Add(y).Add(y)))
-	assert.Equal(t, "(2 + 50) + (50 + 2)", AstToSQL(x.Add(y).Add(y.Add(x))))
-	assert.Equal(t, "((2 + 50) + 50) + 2", AstToSQL(x.Add(y).Add(y).Add(x)))
+	assert.Equal(t, "2 + 50 + 50", AstToSQL(x.Add(y).Add(y)))
+	assert.Equal(t, "2 + 50 + (50 + 2)", AstToSQL(x.Add(y).Add(y.Add(x))))
+	assert.Equal(t, "2 + 50 + 50 + 2", AstToSQL(x.Add(y).Add(y).Add(x)))
+}
+
+func TestPrecedence(t *testing.T) {
+	x := Literal(2)
+	y := Literal(50)
+	col := Column(myTb, "Col").As("NewCol")
+	assert.Equal(t, "2 * (50 + 2)", AstToSQL(x.Mul(y.Add(x))))
+	assert.Equal(t, "2 + 50 * 2", AstToSQL(x.Add(y.Mul(x))))
+	assert.Equal(t, "2 - (50 - 2)", AstToSQL(x.Sub(y.Sub(x))))
+	assert.Equal(t, "2 ^ 50 ^ 2", AstToSQL(x.Exp(y).Exp(x)))
+	assert.Equal(t, `"NewCol" + 2 > 50`, AstToSQL(col.Add(x).Gt(y)))
+	assert.Equal(t, `("NewCol" > 2) = true`, AstToSQL(col.Gt(x).Eq(true)))
+	assert.Equal(t, `"NewCol" > 2 IS NOT NULL`, AstToSQL(col.Gt(x).IsNot(Null)))
+	assert.Equal(t, `"NewCol" || 'a' LIKE 'b%'`, AstToSQL(col.Union("a").Like("b%")))
+	assert.Equal(t, `"NewCol" LIKE 'b%' = false`, AstToSQL(col.Like("b%").Eq(false)))
+	assert.Equal(t, `"NewCol" > 2 AND ("NewCol" < 50 OR "NewCol" = 0)`, AstToSQL(And(col.Gt(x), Or(col.Lt(y), col.Eq(0)))))
+	assert.Equal(t, `"NewCol" > 2 OR "NewCol" < 50 AND "NewCol" = 0`, AstToSQL(Or(col.Gt(x), And(col.Lt(y), col.Eq(0)))))
+	assert.Equal(t, `NOT "NewCol" = 2`, AstToSQL(Not(col.Eq(x))))
+	assert.Equal(t, `NOT ("NewCol" = 2 AND "NewCol" = 50)`, AstToSQL(Not(And(col.Eq(x), col.Eq(y)))))
+	assert.Equal(t, `NOT NOT "NewCol"`, AstToSQL(Not(Not(col))))
+	assert.Equal(t, `-(-"NewCol")`, AstToSQL(Neg(Neg(col))))
+	assert.Equal(t, `@("NewCol" + 2)`, AstToSQL(Abs(col.Add(x))))
+	assert.Equal(t, `- -5`, AstToSQL(Neg(-5)))
+	assert.Equal(t, `@ -5`, AstToSQL(Abs(-5)))
+	assert.Equal(t, `~ -5.5`, AstToSQL(CreateLeftUnaryExpFactory("~")(-5.5)))
+	assert.Equal(t, `@(@"NewCol")`, AstToSQL(Abs(Abs(col))))
+
+	// Operators made by the factories may have any precedence, so they are always parenthesised.
+	distinctFrom := CreateBinaryExpFactory("IS DISTINCT FROM")
+	atTimeZone := CreateBinaryExpFactory("AT TIME ZONE")
+	assert.Equal(t, `("NewCol" IS DISTINCT FROM 2) = true`, AstToSQL(distinctFrom(col, x).Eq(true)))
+	assert.Equal(t, `("NewCol" + 2) AT TIME ZONE 'UTC'`, AstToSQL(atTimeZone(col.Add(x), "UTC")))
+	assert.Equal(t, `"NewCol" IS DISTINCT FROM ("NewCol" IS DISTINCT FROM 2)`,
+		AstToSQL(distinctFrom(col, distinctFrom(col, x))))
+	isTrue := CreateRightUnaryExpFactory("IS TRUE")
+	assert.Equal(t, `NOT ("NewCol" IS TRUE)`, AstToSQL(Not(isTrue(col))))
+	assert.Equal(t, `("NewCol" = 2) IS TRUE`, AstToSQL(isTrue(col.Eq(x))))
+	assert.Equal(t, `"NewCol" ~ 'a' = true`, AstToSQL(col.Match("a", false).Eq(true)))
+
+	ctx := newBuildContext(ContextModeFullParentheses)
+	And(col.Gt(x.Add(y).Add(y)), Not(col.Eq(x))).toSQL(ctx)
+	assert.Equal(t, `("NewCol" > ((2 + 50) + 50)) AND (NOT ("NewCol" = 2))`, ctx.buf.String())
 }
 
 func TestBaseColExpNode_Gte(t *testing.T) {
@@ -124,6 +233,14 @@ func TestBaseColExpNode_Gte(t *testing.T) {
 		AstToSQL(col.Gte(34)))
 }
 
+func TestBaseColExpNode_In(t *testing.T) {
+	col := Column(myTb, "Col")
+	assert.Equal(t, fmt.Sprintf(`"%s"."Col" IN (1, 2)`, myTbTable),
+		AstToSQL(col.In(Tuple(1, 2))))
+	assert.Equal(t, fmt.Sprintf(`"%s"."Col" NOT IN (1, 2)`, myTbTable),
+		AstToSQL(col.NotIn(Tuple(1, 2))))
+}
+
 // TODO: More tests for different operators.
 func TestBaseColExpNode_Intersect(t *testing.T) {
 	col := Column(myTb, "Col")
@@ -149,11 +266,13 @@ func TestAnd(t *testing.T) {
 	assert.Equal(t, `true AND "NewCol" > 75 AND "NewCol" <= 100 AND "NewCol" != 88`, AstToSQL(a))
 
 	a = And(true, col.Gt(75), And(col.Lte(100), col.Ne(88)))
-	assert.Equal(t, `true AND "NewCol" > 75 AND ("NewCol" <= 100 AND "NewCol" != 88)`, AstToSQL(a))
+	assert.Equal(t, `true AND "NewCol" > 75 AND "NewCol" <= 100 AND "NewCol" != 88`, AstToSQL(a))
 
-	assert.Panics(t, func() {
-		And()
-	})
+	assert.Equal(t, "TRUE", AstToSQL(And()))
+	assert.Equal(t, "FALSE", AstToSQL(Or()))
+	var nilCol *ColumnNode
+	assert.Equal(t, `"NewCol" > 75`, AstToSQL(And(nil, col.Gt(75), nilCol, And())))
+	assert.Equal(t, `"NewCol" > 75 OR TRUE`, AstToSQL(Or(nil, col.Gt(75), Or(), And())))
 }
 
 func TestArray(t *testing.T) {
@@ -168,6 +287,52 @@ func TestFuncCall(t *testing.T) {
 	assert.Equal(t, "now()", AstToSQL(fn))
 }
 
+func TestFuncCall_Aggregate(t *testing.T) {
+	col := Column(myTb, "Col")
+	col2 := Column(myTb.As("other"), "Col2")
+	fn := FuncCall("count", col).Distinct()
+	assert.Equal(t, `count(DISTINCT "testTable"."Col")`, AstToSQL(fn))
+
+	fn = FuncCall("string_agg", col, ",").OrderBy(Desc(col2), col)
+	assert.Equal(t, `string_agg("testTable"."Col", ',' ORDER BY "other"."Col2" DESC, "testTable"."Col" ASC)`, AstToSQL(fn))
+
+	fn = FuncCall("sum", col).Filter(col2.Gt(3), col2.Lt(10))
+	assert.Equal(t, `sum("testTable"."Col") FILTER (WHERE "other"."Col2" > 3 AND "other"."Col2" < 10)`, AstToSQL(fn))
+
+	fn = FuncCall("percentile_cont", 0.9).WithinGroup(col).Filter(col2.IsNot(Null))
+	assert.Equal(t, `percentile_cont(0.9) WITHIN GROUP (ORDER BY "testTable"."Col" ASC) FILTER (WHERE "other"."Col2" IS NOT NULL)`, AstToSQL(fn))
+
+	collector := colSrcMap{}
+	FuncCall("count").Filter(col2.Gt(3)).collectColSources(collector)
+	_, in := collector["other"]
+	assert.True(t, in)
+
+	assert.Panics(t, func() {
+		FuncCall("count").Distinct()
+	})
+
+	// Aggregate catalog.
+	assert.Equal(t, `count(*)`, AstToSQL(CountAll()))
+	assert.Equal(t, `count(DISTINCT "testTable"."Col")`, AstToSQL(Count(col).Distinct()))
+	assert.Equal(t, `string_agg("testTable"."Col", ', ' ORDER BY "testTable"."Col" ASC)`,
+		AstToSQL(StringAgg(col, ", ").OrderBy(col)))
+	assert.Equal(t, `percentile_cont(0.5) WITHIN GROUP (ORDER BY "testTable"."Col" DESC)`,
+		AstToSQL(PercentileCont(0.5, Desc(col))))
+	assert.Equal(t, `mode() WITHIN GROUP (ORDER BY "testTable"."Col" ASC)`, AstToSQL(Mode(col)))
+	assert.Equal(t, `rank(42) WITHIN GROUP (ORDER BY "testTable"."Col" ASC)`, AstToSQL(Rank(42).WithinGroup(col)))
+}
+
+func TestGroupingSets(t *testing.T) {
+	a := Column(myTb, "a")
+	b := Column(myTb, "b")
+	assert.Equal(t, `ROLLUP ("testTable"."a", "testTable"."b")`, AstToSQL(Rollup(a, b)))
+	assert.Equal(t, `CUBE (("testTable"."a", "testTable"."b"), "testTable"."a")`,
+		AstToSQL(Cube([]interface{}{a, b}, a)))
+	assert.Equal(t, `GROUPING SETS (("testTable"."a", "testTable"."b"), "testTable"."a", (), ROLLUP ("testTable"."b"))`,
+		AstToSQL(GroupingSets([]interface{}{a, b}, []interface{}{a}, []interface{}{}, Rollup(b))))
+	assert.Equal(t, `GROUPING("testTable"."a", "testTable"."b")`, AstToSQL(Grouping(a, b)))
+}
+
 func TestFuncCallFactory(t *testing.T) {
 	ff := CreateFuncCallFactory("new")
 	assert.Equal(t, "new()", AstToSQL(ff()))
@@ -179,7 +344,7 @@ func TestNeg(t *testing.T) {
 	assert.Equal(t, "-5.78", AstToSQL(n))
 
 	a := n.Add(30)
-	assert.Equal(t, "(-5.78) + 30", AstToSQL(a))
+	assert.Equal(t, "-5.78 + 30", AstToSQL(a))
 }
 
 func TestSubQueryExp(t *testing.T) {
@@ -190,6 +355,34 @@ func TestSubQueryExp(t *testing.T) {
 		myTbTable, myTbSchema, myTbTable), AstToSQL(exp))
 }
 
+func TestOrderExp(t *testing.T) {
+	col := Column(myTb, "Col")
+	assert.Equal(t, `"testTable"."Col" ASC NULLS FIRST`, AstToSQL(Asc(col).NullsFirst()))
+	assert.Equal(t, `"testTable"."Col" COLLATE "C" DESC NULLS LAST`, AstToSQL(Desc(col).Collate("C").NullsLast()))
+	assert.Equal(t, `("testTable"."Col" + 1) USING >`, AstToSQL(OrderUsing(col.Add(1), ">")))
+	for _, op := range []string{"", "> 1; DROP TABLE t; --", "OPERATOR(pg_catalog.<)"} {
+		assert.Panics(t, func() {
+			OrderUsing(col, op)
+		}, op)
+	}
+	assert.Equal(t, `"testTable"."Col" COLLATE "C" < 'b'`, AstToSQL(Collate(col, "C").Lt("b")))
+
+	fn := FuncCall("string_agg", col, ",").OrderBy(Desc(col).NullsLast())
+	assert.Equal(t, `string_agg("testTable"."Col", ',' ORDER BY "testTable"."Col" DESC NULLS LAST)`, AstToSQL(fn))
+}
+
+func TestSubscript(t *testing.T) {
+	col := Column(myTb, "Col")
+	assert.Equal(t, `"testTable"."Col"[1]`, AstToSQL(Subscript(col, 1)))
+	assert.Equal(t, `"testTable"."Col"['a']['b']`, AstToSQL(Subscript(Subscript(col, "a"), "b")))
+	assert.Equal(t, `("testTable"."Col" || ARRAY[1])[2:3]`, AstToSQL(Slice(col.Union(Array(1)), 2, 3)))
+}
+
+func TestRow(t *testing.T) {
+	assert.Equal(t, `(1, 'a')`, AstToSQL(Row(1, "a")))
+	assert.Equal(t, `ROW(1)`, AstToSQL(Row(1)))
+}
+
 func TestSQL(t *testing.T) {
 	assert.Equal(t, `DEFAULT`, AstToSQL(Default))
 }
\ No newline at end of file
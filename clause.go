@@ -1,7 +1,10 @@
 package pgqb
 
 import (
+	"bytes"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 type clause interface {
@@ -9,6 +12,7 @@ type clause interface {
 	collectColSources(collector colSrcMap)
 	isClause()
 	deepcopy() clause
+	Keyword() string
 }
 
 type baseClause struct{}
@@ -19,6 +23,10 @@ func (baseClause) collectColSources(collector colSrcMap) {}
 
 func (baseClause) isClause() {}
 
+func (baseClause) Keyword() string {
+	return ""
+}
+
 func (baseClause) deepcopy() clause {
 	return nil
 }
@@ -43,8 +51,42 @@ func (c *baseColExpListClause) toSQLWithKeyword(keyword string, ctx *buildContex
 		return
 	}
 	ctx.buf.WriteString(keyword + " ")
-	c.toSQL(ctx)
-	ctx.buf.WriteByte(' ')
+	if ctx.Pretty() {
+		c.prettyToSQL(ctx)
+	} else {
+		c.toSQL(ctx)
+	}
+	ctx.lineBreak()
+}
+
+// Keep the list on the current line when it fits; otherwise put every expression on its own indented line.
+func (c *baseColExpListClause) prettyToSQL(ctx *buildContext) {
+	start := ctx.buf.Len()
+	width := start - (bytes.LastIndexByte(ctx.buf.Bytes(), '\n') + 1)
+	wrap := false
+	exps := make([]string, len(c.colExpList))
+	ctx.indent++
+	for i, colExp := range c.colExpList {
+		// Render every expression once, as rendering numbers the arguments.
+		expStart := ctx.buf.Len()
+		colExp.toSQL(ctx)
+		exps[i] = string(ctx.buf.Bytes()[expStart:])
+		width += len(exps[i]) + len(", ")
+		wrap = wrap || strings.ContainsRune(exps[i], '\n')
+	}
+	ctx.buf.Truncate(start)
+	if wrap || width > prettyLineWidth {
+		for i, exp := range exps {
+			ctx.newLine()
+			ctx.buf.WriteString(exp)
+			if i < len(exps)-1 {
+				ctx.buf.WriteByte(',')
+			}
+		}
+	} else {
+		ctx.buf.WriteString(strings.Join(exps, ", "))
+	}
+	ctx.indent--
 }
 
 func (c *baseColExpListClause) collectColSources(collector colSrcMap) {
@@ -58,9 +100,7 @@ func (c *baseColExpListClause) addColExp(exps ... interface{}) {
 }
 
 func (c *baseColExpListClause) deepcopy() clause {
-	var colExpList = make([]ColExp, len(c.colExpList))
-	copy(colExpList, c.colExpList)
-	return &baseColExpListClause{colExpList: colExpList}
+	return &baseColExpListClause{colExpList: deepcopyColExpList(c.colExpList)}
 }
 
 // Select clause.
@@ -72,6 +112,10 @@ func (c *selectClause) toSQL(ctx *buildContext) {
 	c.baseColExpListClause.toSQLWithKeyword("SELECT", ctx)
 }
 
+func (c *selectClause) Keyword() string {
+	return "SELECT"
+}
+
 func (c *selectClause) deepcopy() clause {
 	var baseColExpListClause = c.baseColExpListClause.deepcopy().(*baseColExpListClause)
 	return &selectClause{baseColExpListClause: *baseColExpListClause}
@@ -86,6 +130,10 @@ func (c *returningClause) toSQL(ctx *buildContext) {
 	c.baseColExpListClause.toSQLWithKeyword("RETURNING", ctx)
 }
 
+func (c *returningClause) Keyword() string {
+	return "RETURNING"
+}
+
 func (c *returningClause) deepcopy() clause {
 	var baseColExpListClause = c.baseColExpListClause.deepcopy().(*baseColExpListClause)
 	return &returningClause{baseColExpListClause: *baseColExpListClause}
@@ -100,6 +148,10 @@ func (c *groupByClause) toSQL(ctx *buildContext) {
 	c.baseColExpListClause.toSQLWithKeyword("GROUP BY", ctx)
 }
 
+func (c *groupByClause) Keyword() string {
+	return "GROUP BY"
+}
+
 func (c *groupByClause) deepcopy() clause {
 	var baseColExpListClause = c.baseColExpListClause.deepcopy().(*baseColExpListClause)
 	return &groupByClause{baseColExpListClause: *baseColExpListClause}
@@ -114,6 +166,10 @@ func (c *orderByClause) toSQL(ctx *buildContext) {
 	c.baseColExpListClause.toSQLWithKeyword("ORDER BY", ctx)
 }
 
+func (c *orderByClause) Keyword() string {
+	return "ORDER BY"
+}
+
 func (c *orderByClause) deepcopy() clause {
 	var baseColExpListClause = c.baseColExpListClause.deepcopy().(*baseColExpListClause)
 	return &orderByClause{baseColExpListClause: *baseColExpListClause}
@@ -135,9 +191,18 @@ type defaultValuesClause struct {
 }
 
 func (c *defaultValuesClause) toSQL(ctx *buildContext) {
+	if len(c.colExpList) == 0 {
+		ctx.buf.WriteString("DEFAULT VALUES")
+		ctx.lineBreak()
+		return
+	}
 	c.baseColExpListClause.toSQLWithKeyword("DEFAULT VALUES", ctx)
 }
 
+func (c *defaultValuesClause) Keyword() string {
+	return "DEFAULT VALUES"
+}
+
 func (c *defaultValuesClause) deepcopy() clause {
 	var baseColExpListClause = c.baseColExpListClause.deepcopy().(*baseColExpListClause)
 	return &defaultValuesClause{baseColExpListClause: *baseColExpListClause}
@@ -160,25 +225,35 @@ func (c *basePredicateClause) toSQLWithKeyword(keyword string, ctx *buildContext
 	}
 	ctx.buf.WriteString(keyword + " ")
 	c.toSQL(ctx)
-	ctx.buf.WriteByte(' ')
+	ctx.lineBreak()
 }
 
 func (c *basePredicateClause) collectColSources(collector colSrcMap) {
-	c.predicate.collectColSources(collector)
+	if !isNull(c.predicate) {
+		c.predicate.collectColSources(collector)
+	}
 }
 
+// Nil predicates and empty AND expressions are ignored.
 func (c *basePredicateClause) addPredicate(predicates ... interface{}) {
 	var tmp = make([]interface{}, 0, len(predicates)+1)
 	if c.predicate != nil {
 		tmp = append(tmp, c.predicate)
 	}
-	tmp = append(tmp, predicates...)
-	if len(predicates) > 0 {
+	var added bool
+	for _, predicate := range predicates {
+		if !isIdentityExp(opAnd, predicate) {
+			tmp = append(tmp, predicate)
+			added = true
+		}
+	}
+	if added {
 		c.predicate = And(tmp...)
 	}
 }
 
 func (c basePredicateClause) deepcopy() clause {
+	c.predicate = deepcopyColExp(c.predicate)
 	return &c
 }
 
@@ -191,6 +266,10 @@ func (c *whereClause) toSQL(ctx *buildContext) {
 	c.basePredicateClause.toSQLWithKeyword("WHERE", ctx)
 }
 
+func (c *whereClause) Keyword() string {
+	return "WHERE"
+}
+
 func (c *whereClause) deepcopy() clause {
 	var basePredicateClause = c.basePredicateClause.deepcopy().(*basePredicateClause)
 	return &whereClause{basePredicateClause: *basePredicateClause}
@@ -205,6 +284,10 @@ func (c *havingClause) toSQL(ctx *buildContext) {
 	c.basePredicateClause.toSQLWithKeyword("HAVING", ctx)
 }
 
+func (c *havingClause) Keyword() string {
+	return "HAVING"
+}
+
 func (c *havingClause) deepcopy() clause {
 	var basePredicateClause = c.basePredicateClause.deepcopy().(*basePredicateClause)
 	return &havingClause{basePredicateClause: *basePredicateClause}
@@ -231,7 +314,7 @@ func (c *baseTbExpListClause) toSQLWithKeyword(keyword string, ctx *buildContext
 	}
 	ctx.buf.WriteString(keyword + " ")
 	c.toSQL(ctx)
-	ctx.buf.WriteByte(' ')
+	ctx.lineBreak()
 }
 
 func (c *baseTbExpListClause) collectColSources(collector colSrcMap) {
@@ -250,13 +333,35 @@ func (c *baseTbExpListClause) fillMissingColSrc(colSrcMap colSrcMap) {
 	for _, colSrc := range difference {
 		if tbExp, ok := colSrc.(TableExp); ok {
 			c.addTableExp(tbExp)
+			tbExp.collectColSources(fromColSrcMap)
+		}
+	}
+	// A FROM item may only refer to the items preceding it, so the column sources referenced by
+	// the items themselves (i.e. function arguments) go first.
+	var refTbExps []TableExp
+	for _, colSrc := range c.refColSources().Subtract(fromColSrcMap) {
+		if tbExp, ok := colSrc.(TableExp); ok {
+			refTbExps = append(refTbExps, tbExp)
 		}
 	}
+	if len(refTbExps) > 0 {
+		c.tbExpList = append(refTbExps, c.tbExpList...)
+	}
+}
+
+func (c *baseTbExpListClause) refColSources() colSrcMap {
+	res := colSrcMap{}
+	for _, tbExp := range c.tbExpList {
+		tbExp.collectRefColSources(res)
+	}
+	return res
 }
 
 func (c *baseTbExpListClause) deepcopy() clause {
 	var tbExpList = make([]TableExp, len(c.tbExpList))
-	copy(tbExpList, c.tbExpList)
+	for i, tbExp := range c.tbExpList {
+		tbExpList[i] = deepcopyTableExp(tbExp)
+	}
 	return &baseTbExpListClause{tbExpList: tbExpList}
 }
 
@@ -269,12 +374,26 @@ func (c *fromClause) toSQL(ctx *buildContext) {
 	c.baseTbExpListClause.toSQLWithKeyword("FROM", ctx)
 }
 
+func (c *fromClause) Keyword() string {
+	return "FROM"
+}
+
+// Return a copy including the missing column sources; the receiver may be nil.
+func (c *fromClause) withMissingColSrc(colSrcMap colSrcMap) *fromClause {
+	res := &fromClause{}
+	if c != nil {
+		res.tbExpList = c.tbExpList[:len(c.tbExpList):len(c.tbExpList)]
+	}
+	res.fillMissingColSrc(colSrcMap)
+	return res
+}
+
 func (c *fromClause) deepcopy() clause {
 	var baseTbExpListClause = *c.baseTbExpListClause.deepcopy().(*baseTbExpListClause)
 	return &fromClause{baseTbExpListClause: baseTbExpListClause}
 }
 
-// From clause.
+// Using clause.
 type usingClause struct {
 	baseTbExpListClause
 }
@@ -283,77 +402,239 @@ func (c *usingClause) toSQL(ctx *buildContext) {
 	c.baseTbExpListClause.toSQLWithKeyword("USING", ctx)
 }
 
+func (c *usingClause) Keyword() string {
+	return "USING"
+}
+
+// Return a copy including the missing column sources; the receiver may be nil.
+func (c *usingClause) withMissingColSrc(colSrcMap colSrcMap) *usingClause {
+	res := &usingClause{}
+	if c != nil {
+		res.tbExpList = c.tbExpList[:len(c.tbExpList):len(c.tbExpList)]
+	}
+	res.fillMissingColSrc(colSrcMap)
+	return res
+}
+
 func (c *usingClause) deepcopy() clause {
 	var baseTbExpListClause = *c.baseTbExpListClause.deepcopy().(*baseTbExpListClause)
 	return &usingClause{baseTbExpListClause: baseTbExpListClause}
 }
 
 // Set clause.
+type setAssignment struct {
+	target SetTarget
+	value  ColExp
+}
+
 type setClause struct {
-	// Column names -> ColExp
-	setExpMap map[string]ColExp
+	// Assignments in rendering order.
+	assignments []*setAssignment
 }
 
 func (c *setClause) toSQL(ctx *buildContext) {
-	if len(c.setExpMap) == 0 {
+	if len(c.assignments) == 0 {
 		return
 	}
 	ctx.buf.WriteString("SET ")
-	i := 0
-	for cname, exp := range c.setExpMap {
+	for i, assignment := range c.assignments {
 		if i > 0 {
 			ctx.buf.WriteString(", ")
 		}
-		ctx.buf.WriteString(ctx.QuoteObject(cname) + " = ")
-		exp.toSQL(ctx)
-		i++
+		origState := ctx.setState(buildContextStateNoColumnSource)
+		assignment.target.toSQL(ctx)
+		ctx.setState(origState)
+		ctx.buf.WriteString(" = ")
+		assignment.value.toSQL(ctx)
 	}
-	ctx.buf.WriteByte(' ')
+	ctx.lineBreak()
+}
+
+func (c *setClause) Keyword() string {
+	return "SET"
 }
 
 func (c *setClause) collectColSources(collector colSrcMap) {
-	for _, exp := range c.setExpMap {
-		exp.collectColSources(collector)
+	for _, assignment := range c.assignments {
+		assignment.value.collectColSources(collector)
 	}
 }
 
 func (c *setClause) deepcopy() clause {
-	setExpMap := make(map[string]ColExp, len(c.setExpMap))
-	for k, v := range c.setExpMap {
-		setExpMap[k] = v
+	var assignments = make([]*setAssignment, len(c.assignments))
+	for i, assignment := range c.assignments {
+		assignments[i] = &setAssignment{
+			target: deepcopyColExp(assignment.target.(ColExp)).(SetTarget),
+			value:  deepcopyColExp(assignment.value),
+		}
 	}
-	return &setClause{setExpMap: setExpMap}
+	return &setClause{assignments: assignments}
 }
 
 func (setClause) isClause() {}
 
+// Replace the assignment to the same target, or append a new one.
+func (c *setClause) set(target SetTarget, value interface{}) {
+	assignment := &setAssignment{target: target, value: getExp(value)}
+	key := setTargetKey(target)
+	for i, a := range c.assignments {
+		if setTargetKey(a.target) == key {
+			c.assignments[i] = assignment
+			return
+		}
+	}
+	c.assignments = append(c.assignments, assignment)
+}
+
+func (c *setClause) unset(target SetTarget) {
+	key := setTargetKey(target)
+	for i, a := range c.assignments {
+		if setTargetKey(a.target) == key {
+			c.assignments = append(c.assignments[:i:i], c.assignments[i+1:]...)
+			return
+		}
+	}
+}
+
+// Assignments of a Set are ordered by their targets, as map iteration order is random.
+func newSetClause(set Set) *setClause {
+	c := &setClause{}
+	keys := make([]string, 0, len(set))
+	targets := make(map[string]SetTarget, len(set))
+	for target := range set {
+		key := setTargetKey(target)
+		keys = append(keys, key)
+		targets[key] = target
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c.set(targets[key], set[targets[key]])
+	}
+	return c
+}
+
+// Identify the assignment target (i.e. "col", ("a", "b"), "col"[1], "col"[:i]). Arguments are identified by their
+// tags, as their numbers depend on the rest of the statement; untagged ones are not allowed.
+func setTargetKey(target SetTarget) string {
+	ctx := newBuildContext(ContextModeNone)
+	ctx.state = buildContextStateNoColumnSource
+	ctx.argTags = true
+	target.toSQL(ctx)
+	return ctx.buf.String()
+}
+
 // Conflict clause.
 type conflictClause struct {
 	*setClause
-	cols []*ColumnNode
+	target      *ConflictTarget
+	whereClause *whereClause
 }
 
 func (c *conflictClause) toSQL(ctx *buildContext) {
-	origState := ctx.setState(buildContextStateNoColumnSource)
-	ctx.buf.WriteString("ON CONFLICT (")
-	for i, col := range c.cols {
-		if i > 0 {
-			ctx.buf.WriteString(", ")
-		}
-		col.toSQL(ctx)
+	ctx.buf.WriteString("ON CONFLICT ")
+	if c.target != nil {
+		c.target.toSQL(ctx)
 	}
-	ctx.buf.WriteString(") ")
-	ctx.setState(origState)
 	if isNull(c.setClause) {
 		ctx.buf.WriteString("DO NOTHING")
+		ctx.lineBreak()
 	} else {
 		ctx.buf.WriteString("DO UPDATE ")
 		c.setClause.toSQL(ctx)
+		clauseToSQL(c.whereClause, ctx)
+	}
+}
+
+func (c *conflictClause) Keyword() string {
+	return "ON CONFLICT"
+}
+
+func (c *conflictClause) collectColSources(collector colSrcMap) {
+	if !isNull(c.setClause) {
+		c.setClause.collectColSources(collector)
+	}
+	if !isNull(c.whereClause) {
+		c.whereClause.collectColSources(collector)
 	}
 }
 
 func (c *conflictClause) deepcopy() clause {
-	return &conflictClause{setClause: c.setClause.deepcopy().(*setClause)}
+	res := &conflictClause{}
+	if !isNull(c.setClause) {
+		res.setClause = c.setClause.deepcopy().(*setClause)
+	}
+	res.whereClause = deepcopyClause(c.whereClause).(*whereClause)
+	if c.target != nil {
+		res.target = c.target.deepcopy()
+	}
+	return res
+}
+
+// Only update the conflicting rows satisfying all the predicates.
+func (c *conflictClause) Where(exps ... interface{}) *conflictClause {
+	if len(exps) == 0 {
+		return c
+	}
+	if c.whereClause == nil {
+		c.whereClause = &whereClause{}
+	}
+	c.whereClause.addPredicate(exps...)
+	return c
+}
+
+// Conflict target, i.e. an index inference specification or a named constraint.
+type ConflictTarget struct {
+	exps        []ColExp
+	whereClause *whereClause
+	constraint  string
+}
+
+func (t *ConflictTarget) toSQL(ctx *buildContext) {
+	if t.constraint != "" {
+		ctx.buf.WriteString("ON CONSTRAINT " + ctx.QuoteObject(t.constraint) + " ")
+		return
+	}
+	if len(t.exps) == 0 {
+		return
+	}
+	// Index columns and predicates always refer to the target table.
+	origState := ctx.setState(buildContextStateNoColumnSource)
+	ctx.buf.WriteByte('(')
+	for i, exp := range t.exps {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		if _, ok := exp.(*ColumnNode); ok {
+			exp.toSQL(ctx)
+		} else {
+			ctx.buf.WriteByte('(')
+			exp.toSQL(ctx)
+			ctx.buf.WriteByte(')')
+		}
+	}
+	ctx.buf.WriteString(") ")
+	clauseToSQL(t.whereClause, ctx)
+	ctx.setState(origState)
+}
+
+// Only infer partial unique indexes whose predicates are implied by all the given ones.
+func (t *ConflictTarget) Where(exps ... interface{}) *ConflictTarget {
+	if len(exps) == 0 {
+		return t
+	}
+	if t.whereClause == nil {
+		t.whereClause = &whereClause{}
+	}
+	t.whereClause.addPredicate(exps...)
+	return t
+}
+
+func (t *ConflictTarget) deepcopy() *ConflictTarget {
+	return &ConflictTarget{
+		exps:        deepcopyColExpList(t.exps),
+		whereClause: deepcopyClause(t.whereClause).(*whereClause),
+		constraint:  t.constraint,
+	}
 }
 
 // Insert clause.
@@ -376,10 +657,14 @@ func (c *insertClause) toSQL(ctx *buildContext) {
 		}
 		ctx.buf.WriteByte(')')
 	}
-	ctx.buf.WriteByte(' ')
+	ctx.lineBreak()
 	ctx.setState(origState)
 }
 
+func (c *insertClause) Keyword() string {
+	return "INSERT INTO"
+}
+
 func (c *insertClause) collectColSources(collector colSrcMap) {}
 
 func (c *insertClause) deepcopy() clause {
@@ -401,21 +686,12 @@ type valuesClause struct {
 }
 
 func (c *valuesClause) toSQL(ctx *buildContext) {
-	ctx.buf.WriteString("VALUES ")
-	for i, valList := range c.valuesList {
-		if i > 0 {
-			ctx.buf.WriteString(", ")
-		}
-		ctx.buf.WriteByte('(')
-		for j, val := range valList {
-			if j > 0 {
-				ctx.buf.WriteString(", ")
-			}
-			val.toSQL(ctx)
-		}
-		ctx.buf.WriteByte(')')
-	}
-	ctx.buf.WriteByte(' ')
+	valuesListToSQL(c.valuesList, ctx)
+	ctx.lineBreak()
+}
+
+func (c *valuesClause) Keyword() string {
+	return "VALUES"
 }
 
 func (c *valuesClause) collectColSources(collector colSrcMap) {
@@ -428,7 +704,9 @@ func (c *valuesClause) collectColSources(collector colSrcMap) {
 
 func (c *valuesClause) deepcopy() clause {
 	var valuesList = make([][]ColExp, len(c.valuesList))
-	copy(valuesList, c.valuesList)
+	for i, valList := range c.valuesList {
+		valuesList[i] = deepcopyColExpList(valList)
+	}
 	return &valuesClause{valuesList: valuesList}
 }
 
@@ -445,6 +723,10 @@ func (c *subqueryClause) toSQL(ctx *buildContext) {
 	c.selectStmt.toSQL(ctx)
 }
 
+func (c *subqueryClause) Keyword() string {
+	return "SELECT"
+}
+
 func (c *subqueryClause) collectColSources(collector colSrcMap) {}
 
 func (c *subqueryClause) isClause() {}
@@ -455,6 +737,132 @@ func (c *subqueryClause) deepcopy() clause {
 
 func (c *subqueryClause) isValueSource() {}
 
+// Merge action (i.e. THEN UPDATE SET ..., THEN INSERT ... VALUES ...).
+type mergeAction struct {
+	baseClause
+	op            string
+	setClause     *setClause
+	columns       []*ColumnNode
+	values        []ColExp
+	defaultValues bool
+}
+
+const (
+	mergeUpdate    string = "UPDATE"
+	mergeDelete           = "DELETE"
+	mergeInsert           = "INSERT"
+	mergeDoNothing        = "DO NOTHING"
+)
+
+func (c *mergeAction) toSQL(ctx *buildContext) {
+	ctx.buf.WriteString(c.op)
+	switch c.op {
+	case mergeUpdate:
+		ctx.buf.WriteByte(' ')
+		c.setClause.toSQL(ctx)
+	case mergeInsert:
+		ctx.buf.WriteByte(' ')
+		if len(c.columns) > 0 {
+			origState := ctx.setState(buildContextStateNoColumnSource)
+			ctx.buf.WriteByte('(')
+			for i, col := range c.columns {
+				if i > 0 {
+					ctx.buf.WriteString(", ")
+				}
+				col.toSQL(ctx)
+			}
+			ctx.buf.WriteString(") ")
+			ctx.setState(origState)
+		}
+		if c.defaultValues {
+			ctx.buf.WriteString("DEFAULT VALUES")
+		} else {
+			valuesListToSQL([][]ColExp{c.values}, ctx)
+		}
+		ctx.lineBreak()
+	default:
+		ctx.lineBreak()
+	}
+}
+
+func (c *mergeAction) Keyword() string {
+	return "THEN " + c.op
+}
+
+func (c *mergeAction) collectColSources(collector colSrcMap) {
+	if !isNull(c.setClause) {
+		c.setClause.collectColSources(collector)
+	}
+	for _, val := range c.values {
+		val.collectColSources(collector)
+	}
+}
+
+func (c *mergeAction) deepcopy() clause {
+	res := &mergeAction{op: c.op, defaultValues: c.defaultValues}
+	if !isNull(c.setClause) {
+		res.setClause = c.setClause.deepcopy().(*setClause)
+	}
+	res.columns = make([]*ColumnNode, len(c.columns))
+	copy(res.columns, c.columns)
+	res.values = deepcopyColExpList(c.values)
+	return res
+}
+
+// Set the values of the row to insert.
+func (c *mergeAction) Values(exps ... interface{}) *mergeAction {
+	if c.op != mergeInsert {
+		panic("invalid operation")
+	}
+	c.values = getExpList(exps)
+	c.defaultValues = false
+	return c
+}
+
+// WHEN [NOT] MATCHED clause of MERGE.
+type mergeWhenClause struct {
+	baseClause
+	match     string
+	condition ColExp
+	action    *mergeAction
+}
+
+const (
+	whenMatched            string = "MATCHED"
+	whenNotMatched                = "NOT MATCHED"
+	whenNotMatchedBySource        = "NOT MATCHED BY SOURCE"
+)
+
+func (c *mergeWhenClause) toSQL(ctx *buildContext) {
+	ctx.buf.WriteString("WHEN " + c.match + " ")
+	if !isNull(c.condition) {
+		ctx.buf.WriteString("AND ")
+		c.condition.toSQL(ctx)
+		ctx.buf.WriteByte(' ')
+	}
+	ctx.buf.WriteString("THEN ")
+	c.action.toSQL(ctx)
+}
+
+func (c *mergeWhenClause) Keyword() string {
+	return "WHEN"
+}
+
+func (c *mergeWhenClause) collectColSources(collector colSrcMap) {
+	if !isNull(c.condition) {
+		c.condition.collectColSources(collector)
+	}
+	c.action.collectColSources(collector)
+}
+
+func (c *mergeWhenClause) deepcopy() clause {
+	return &mergeWhenClause{
+		match:     c.match,
+		condition: deepcopyColExp(c.condition),
+		action:    c.action.deepcopy().(*mergeAction),
+	}
+}
+
 // Helper functions.
 func collectColSourcesFromClauses(clauses ... clause) colSrcMap {
 	res := colSrcMap{}
@@ -466,6 +874,23 @@ func collectColSourcesFromClauses(clauses ... clause) colSrcMap {
 	return res
 }
 
+func valuesListToSQL(valuesList [][]ColExp, ctx *buildContext) {
+	ctx.buf.WriteString("VALUES ")
+	for i, valList := range valuesList {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		ctx.buf.WriteByte('(')
+		for j, val := range valList {
+			if j > 0 {
+				ctx.buf.WriteString(", ")
+			}
+			val.toSQL(ctx)
+		}
+		ctx.buf.WriteByte(')')
+	}
+}
+
 func clauseToSQL(clause clause, ctx *buildContext) {
 	if !isNull(clause) {
 		clause.toSQL(ctx)
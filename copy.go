@@ -0,0 +1,161 @@
+package pgqb
+
+// Deep-copy helpers for expression trees. Leaf nodes (i.e. columns, literals, arguments, tables) never change
+// after being created and are shared between the copies.
+
+func deepcopyColExp(exp ColExp) ColExp {
+	if isNull(exp) {
+		return exp
+	}
+	switch n := exp.(type) {
+	case *SQLNode:
+		node := *n
+		node.args = deepcopyColExpList(n.args)
+		node.ColExp = &node
+		return &node
+	case *ColExpAliasNode:
+		node := *n
+		node.exp = deepcopyColExp(n.exp)
+		node.ColExp = &node
+		return &node
+	case *GroupExpNode:
+		node := *n
+		node.exp = deepcopyColExp(n.exp)
+		node.ColExp = &node
+		return &node
+	case *UnaryExpNode:
+		node := *n
+		node.exp = deepcopyColExp(n.exp)
+		node.ColExp = &node
+		return &node
+	case *OrderExpNode:
+		node := *n
+		node.exp = deepcopyColExp(n.exp)
+		node.ColExp = &node
+		return &node
+	case *CollateNode:
+		node := *n
+		node.exp = deepcopyColExp(n.exp)
+		node.ColExp = &node
+		return &node
+	case *BinaryExpNode:
+		node := *n
+		node.left = deepcopyColExp(n.left)
+		node.right = deepcopyColExp(n.right)
+		node.ColExp = &node
+		return &node
+	case *MultiExpNode:
+		node := *n
+		node.expList = deepcopyColExpList(n.expList)
+		node.ColExp = &node
+		return &node
+	case *LogicalExpNode:
+		node := *n
+		node.expList = deepcopyColExpList(n.expList)
+		node.ColExp = &node
+		return &node
+	case *RowNode:
+		node := *n
+		node.expList = deepcopyColExpList(n.expList)
+		node.ColExp = &node
+		return &node
+	case *FuncCallNode:
+		node := *n
+		node.expList = deepcopyColExpList(n.expList)
+		node.orderBy = deepcopyClause(n.orderBy).(*orderByClause)
+		node.withinGroup = deepcopyClause(n.withinGroup).(*orderByClause)
+		node.filter = deepcopyClause(n.filter).(*whereClause)
+		node.ColExp = &node
+		return &node
+	case *GroupingSetsNode:
+		node := *n
+		node.sets = make([][]ColExp, len(n.sets))
+		for i, set := range n.sets {
+			node.sets[i] = deepcopyColExpList(set)
+		}
+		node.ColExp = &node
+		return &node
+	case *ColumnListNode:
+		node := *n
+		node.cols = append([]*ColumnNode{}, n.cols...)
+		node.ColExp = &node
+		return &node
+	case *SubscriptNode:
+		node := *n
+		node.exp = deepcopyColExp(n.exp)
+		node.index = deepcopyColExp(n.index)
+		node.upper = deepcopyColExp(n.upper)
+		node.ColExp = &node
+		return &node
+	case *SubQueryColExpNode:
+		node := *n
+		node.selectStmt = n.selectStmt.Make()
+		node.ColExp = &node
+		return &node
+	}
+	return exp
+}
+
+func deepcopyColExpList(exps []ColExp) []ColExp {
+	if exps == nil {
+		return nil
+	}
+	res := make([]ColExp, len(exps))
+	for i, exp := range exps {
+		res[i] = deepcopyColExp(exp)
+	}
+	return res
+}
+
+func deepcopyTableExp(exp TableExp) TableExp {
+	if isNull(exp) {
+		return exp
+	}
+	switch n := exp.(type) {
+	case *TableAliasNode:
+		node := *n
+		if table, ok := n.table.(TableExp); ok {
+			node.table = deepcopyTableExp(table).(ColSource)
+		}
+		node.TableExp = &node
+		return &node
+	case *JoinNode:
+		node := *n
+		node.src = deepcopyTableExp(n.src)
+		node.dst = deepcopyTableExp(n.dst)
+		node.exp = deepcopyColExp(n.exp)
+		node.usingCols = append([]*ColumnNode{}, n.usingCols...)
+		node.TableExp = &node
+		return &node
+	case *LateralNode:
+		node := *n
+		node.exp = deepcopyTableExp(n.exp)
+		node.TableExp = &node
+		return &node
+	case *SubQueryTableExpNode:
+		node := *n
+		node.selectStmt = n.selectStmt.Make()
+		node.TableExp = &node
+		return &node
+	case *ValuesTableExpNode:
+		node := *n
+		node.colNames = append([]string{}, n.colNames...)
+		node.valuesList = make([][]ColExp, len(n.valuesList))
+		for i, values := range n.valuesList {
+			node.valuesList[i] = deepcopyColExpList(values)
+		}
+		node.TableExp = &node
+		return &node
+	case *TableFuncNode:
+		node := *n
+		node.funcs = make([]*FuncCallNode, len(n.funcs))
+		for i, fn := range n.funcs {
+			node.funcs[i] = deepcopyColExp(fn).(*FuncCallNode)
+		}
+		node.colNames = append([]string{}, n.colNames...)
+		node.colTypes = append([]string{}, n.colTypes...)
+		node.TableExp = &node
+		return &node
+	}
+	return exp
+}
@@ -1,21 +1,32 @@
 package pgqb
 
-import "bytes"
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
 
 // SQL context.
 type Context struct {
 	mode ContextMode
+	// Schema.table -> predicates (see WithTableFilter).
+	tableFilters map[string][]ColExp
 }
 
 var contextWithNoMode = &Context{mode: ContextModeNone}
 
 func (ctx *Context) createBuildContext() *buildContext {
-	return newBuildContext(ctx.mode)
+	res := newBuildContext(ctx.mode)
+	res.tableFilters = ctx.tableFilters
+	return res
 }
 
 func (ctx *Context) ToSQL(stmt Stmt) string {
 	bCtx := ctx.createBuildContext()
 	stmt.toSQL(bCtx)
+	if bCtx.Pretty() {
+		bCtx.trimTrailingSpace(true)
+	}
 	return bCtx.buf.String()
 }
 
@@ -24,6 +35,11 @@ func NewContext() *Context {
 	return &Context{mode: ContextModeAutoFrom}
 }
 
+// Create a context using the given modes (i.e. ContextModeAutoFrom | ContextModeFullParentheses).
+func NewContextWithMode(mode ContextMode) *Context {
+	return &Context{mode: mode}
+}
+
 //
 type ContextMode int64
 
@@ -32,6 +48,10 @@ const ContextModeNone = ContextMode(0)
 const (
 	ContextModeNamedArgument ContextMode = 1 << iota
 	ContextModeAutoFrom                  = 1 << iota
+	// Surround every nested expression with an operator in parentheses, regardless of precedence.
+	ContextModeFullParentheses = 1 << iota
+	// Put every clause on its own line and indent subqueries and joins, for reading rather than caching.
+	ContextModePretty = 1 << iota
 )
 
 type buildContextState int8
@@ -51,12 +71,36 @@ type buildContext struct {
 
 	currArgNum  int
 	namedArgNum map[string]int
+
+	// Indentation level in ContextModePretty.
+	indent int
+
+	// Replace the values with placeholders (see NormalizeSQL).
+	normalize bool
+
+	// Schema.table -> predicates (see Context.WithTableFilter).
+	tableFilters map[string][]ColExp
+
+	// Render the arguments by their tags rather than their numbers (see setTargetKey).
+	argTags bool
+
+	// Values inlined in place of the arguments by ToDebugSQL.
+	argValues      []interface{}
+	namedArgValues map[string]interface{}
 }
 
 func (ctx *buildContext) NamedArgumentMode() bool {
 	return ctx.mode&ContextModeNamedArgument != ContextModeNone
 }
 
+func (ctx *buildContext) FullParentheses() bool {
+	return ctx.mode&ContextModeFullParentheses != ContextModeNone
+}
+
+func (ctx *buildContext) Pretty() bool {
+	return ctx.mode&ContextModePretty != ContextModeNone
+}
+
 // Automatically fill in missing column sources to the FROM clause.
 func (ctx *buildContext) AutoFrom() bool {
 	return ctx.mode&ContextModeAutoFrom != ContextModeNone
@@ -80,8 +124,58 @@ func (ctx *buildContext) nextArgNum() int {
 	return ctx.currArgNum
 }
 
+// Quote an identifier, doubling the quotes in it.
 func (ctx *buildContext) QuoteObject(name string) string {
-	return `"` + name + `"`
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Indentation unit and preferred line width in ContextModePretty.
+const (
+	prettyIndent    = "  "
+	prettyLineWidth = 80
+)
+
+// Separate two parts of a statement (i.e. clauses) by a space, or by a line break in ContextModePretty.
+func (ctx *buildContext) lineBreak() {
+	if ctx.Pretty() {
+		ctx.newLine()
+	} else {
+		ctx.buf.WriteByte(' ')
+	}
+}
+
+// Start a new line at the current indentation, without leaving trailing spaces on the current one.
+func (ctx *buildContext) newLine() {
+	ctx.trimTrailingSpace(false)
+	ctx.buf.WriteByte('\n')
+	ctx.buf.WriteString(strings.Repeat(prettyIndent, ctx.indent))
+}
+
+// Remove the trailing spaces (and line breaks, if lineBreaks) from the output.
+func (ctx *buildContext) trimTrailingSpace(lineBreaks bool) {
+	b := ctx.buf.Bytes()
+	n := len(b)
+	for n > 0 && (b[n-1] == ' ' || (lineBreaks && b[n-1] == '\n')) {
+		n--
+	}
+	ctx.buf.Truncate(n)
+}
+
+// Render a statement surrounded by parentheses (i.e. a subquery); ContextModePretty puts it on its own indented
+// lines.
+func (ctx *buildContext) parenthesizedStmtToSQL(stmt Stmt) {
+	ctx.buf.WriteByte('(')
+	if !ctx.Pretty() {
+		stmt.toSQL(ctx)
+	} else {
+		ctx.indent++
+		ctx.newLine()
+		stmt.toSQL(ctx)
+		ctx.trimTrailingSpace(true)
+		ctx.indent--
+		ctx.newLine()
+	}
+	ctx.buf.WriteByte(')')
 }
 
 // Return the old state.
@@ -103,12 +197,18 @@ func newBuildContext(mode ContextMode) *buildContext {
 // Table/view/alias name -> ColSource instance
 type colSrcMap map[string]ColSource
 
+// Sorted by name, so that the output does not depend on the map iteration order.
 func (m colSrcMap) Subtract(srcMap colSrcMap) []ColSource {
-	var res []ColSource
-	for s, colSrc := range m {
+	var names []string
+	for s := range m {
 		if _, in := srcMap[s]; !in {
-			res = append(res, colSrc)
+			names = append(names, s)
 		}
 	}
+	sort.Strings(names)
+	res := make([]ColSource, len(names))
+	for i, s := range names {
+		res[i] = m[s]
+	}
 	return res
 }
@@ -0,0 +1,709 @@
+package pgqb
+
+// Column definition (i.e. "id" bigint NOT NULL PRIMARY KEY).
+type ColumnDefNode struct {
+	col        *ColumnNode
+	dataType   string
+	notNull    bool
+	primaryKey bool
+	unique     bool
+	defaultExp ColExp
+	check      ColExp
+	references *ColumnNode
+}
+
+func (n *ColumnDefNode) clone() *ColumnDefNode {
+	res := *n
+	res.defaultExp = deepcopyColExp(n.defaultExp)
+	res.check = deepcopyColExp(n.check)
+	return &res
+}
+
+func (n *ColumnDefNode) toSQL(ctx *buildContext) {
+	ctx.buf.WriteString(ctx.QuoteObject(n.col.name) + " " + n.dataType)
+	if n.defaultExp != nil {
+		ctx.buf.WriteString(" DEFAULT ")
+		n.defaultExp.toSQL(ctx)
+	}
+	if n.notNull {
+		ctx.buf.WriteString(" NOT NULL")
+	}
+	if n.primaryKey {
+		ctx.buf.WriteString(" PRIMARY KEY")
+	}
+	if n.unique {
+		ctx.buf.WriteString(" UNIQUE")
+	}
+	if n.check != nil {
+		ctx.buf.WriteString(" CHECK (")
+		n.check.toSQL(ctx)
+		ctx.buf.WriteByte(')')
+	}
+	if n.references != nil {
+		ctx.buf.WriteString(" REFERENCES ")
+		referencedTable(n.references).toSQL(ctx)
+		ctx.buf.WriteString(" (" + ctx.QuoteObject(n.references.name) + ")")
+	}
+}
+
+func (n *ColumnDefNode) NotNull() *ColumnDefNode {
+	n.notNull = true
+	return n
+}
+
+func (n *ColumnDefNode) Default(exp interface{}) *ColumnDefNode {
+	n.defaultExp = getExp(exp)
+	return n
+}
+
+func (n *ColumnDefNode) PrimaryKey() *ColumnDefNode {
+	n.primaryKey = true
+	return n
+}
+
+func (n *ColumnDefNode) Unique() *ColumnDefNode {
+	n.unique = true
+	return n
+}
+
+func (n *ColumnDefNode) Check(exps ... interface{}) *ColumnDefNode {
+	n.check = And(exps...)
+	return n
+}
+
+// Column of another table this column refers to.
+func (n *ColumnDefNode) References(col *ColumnNode) *ColumnDefNode {
+	n.references = col
+	return n
+}
+
+func ColumnDef(col *ColumnNode, dataType string) *ColumnDefNode {
+	return &ColumnDefNode{col: col, dataType: dataType}
+}
+
+// Table constraint (i.e. PRIMARY KEY, UNIQUE, CHECK, FOREIGN KEY).
+type TableConstraintNode struct {
+	name     string
+	kind     string
+	cols     []*ColumnNode
+	check    ColExp
+	refCols  []*ColumnNode
+	onDelete ReferentialAction
+	onUpdate ReferentialAction
+}
+
+const (
+	constraintPrimaryKey string = "PRIMARY KEY"
+	constraintUnique            = "UNIQUE"
+	constraintCheck             = "CHECK"
+	constraintForeignKey        = "FOREIGN KEY"
+)
+
+type ReferentialAction string
+
+const (
+	RefNoAction   ReferentialAction = "NO ACTION"
+	RefRestrict                     = "RESTRICT"
+	RefCascade                      = "CASCADE"
+	RefSetNull                      = "SET NULL"
+	RefSetDefault                   = "SET DEFAULT"
+)
+
+func (n *TableConstraintNode) toSQL(ctx *buildContext) {
+	if n.name != "" {
+		ctx.buf.WriteString("CONSTRAINT " + ctx.QuoteObject(n.name) + " ")
+	}
+	ctx.buf.WriteString(n.kind + " (")
+	if n.kind == constraintCheck {
+		n.check.toSQL(ctx)
+		ctx.buf.WriteByte(')')
+		return
+	}
+	columnNamesToSQL(n.cols, ctx)
+	ctx.buf.WriteByte(')')
+	if n.kind != constraintForeignKey {
+		return
+	}
+	if len(n.refCols) == 0 {
+		panic("foreign key must reference at least one column")
+	}
+	ctx.buf.WriteString(" REFERENCES ")
+	referencedTable(n.refCols[0]).toSQL(ctx)
+	ctx.buf.WriteString(" (")
+	columnNamesToSQL(n.refCols, ctx)
+	ctx.buf.WriteByte(')')
+	if n.onDelete != "" {
+		ctx.buf.WriteString(" ON DELETE " + string(n.onDelete))
+	}
+	if n.onUpdate != "" {
+		ctx.buf.WriteString(" ON UPDATE " + string(n.onUpdate))
+	}
+}
+
+func (n *TableConstraintNode) Named(name string) *TableConstraintNode {
+	n.name = name
+	return n
+}
+
+// Columns (of the same table) the foreign key refers to.
+func (n *TableConstraintNode) References(cols ... *ColumnNode) *TableConstraintNode {
+	if n.kind != constraintForeignKey {
+		panic("invalid operation")
+	}
+	n.refCols = cols
+	return n
+}
+
+func (n *TableConstraintNode) OnDelete(action ReferentialAction) *TableConstraintNode {
+	n.onDelete = action
+	return n
+}
+
+func (n *TableConstraintNode) OnUpdate(action ReferentialAction) *TableConstraintNode {
+	n.onUpdate = action
+	return n
+}
+
+func (n *TableConstraintNode) clone() *TableConstraintNode {
+	res := *n
+	res.cols = append([]*ColumnNode{}, n.cols...)
+	res.check = deepcopyColExp(n.check)
+	res.refCols = append([]*ColumnNode{}, n.refCols...)
+	return &res
+}
+
+func PrimaryKey(cols ... *ColumnNode) *TableConstraintNode {
+	return &TableConstraintNode{kind: constraintPrimaryKey, cols: cols}
+}
+
+func Unique(cols ... *ColumnNode) *TableConstraintNode {
+	return &TableConstraintNode{kind: constraintUnique, cols: cols}
+}
+
+func Check(exps ... interface{}) *TableConstraintNode {
+	return &TableConstraintNode{kind: constraintCheck, check: And(exps...)}
+}
+
+func ForeignKey(cols ... *ColumnNode) *TableConstraintNode {
+	return &TableConstraintNode{kind: constraintForeignKey, cols: cols}
+}
+
+// Create table statement.
+type PartitionMethod string
+
+const (
+	PartitionByRange PartitionMethod = "RANGE"
+	PartitionByList                  = "LIST"
+	PartitionByHash                  = "HASH"
+)
+
+type CreateTableStmt struct {
+	table         *TableNode
+	ifNotExists   bool
+	columns       []*ColumnDefNode
+	constraints   []*TableConstraintNode
+	partitionBy   PartitionMethod
+	partitionKeys []ColExp
+}
+
+func (s *CreateTableStmt) isStmt() {}
+
+func (s *CreateTableStmt) toSQL(ctx *buildContext) {
+	origState := ctx.setState(buildContextStateNoColumnSource)
+	ctx.buf.WriteString("CREATE TABLE ")
+	if s.ifNotExists {
+		ctx.buf.WriteString("IF NOT EXISTS ")
+	}
+	s.table.toSQL(ctx)
+	ctx.buf.WriteString(" (")
+	for i, col := range s.columns {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		col.toSQL(ctx)
+	}
+	for i, constraint := range s.constraints {
+		if i > 0 || len(s.columns) > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		constraint.toSQL(ctx)
+	}
+	ctx.buf.WriteByte(')')
+	if s.partitionBy != "" {
+		ctx.buf.WriteString(" PARTITION BY " + string(s.partitionBy) + " (")
+		indexElemsToSQL(s.partitionKeys, ctx)
+		ctx.buf.WriteByte(')')
+	}
+	ctx.setState(origState)
+}
+
+func (s *CreateTableStmt) IfNotExists() *CreateTableStmt {
+	s.ifNotExists = true
+	return s
+}
+
+func (s *CreateTableStmt) Columns(defs ... *ColumnDefNode) *CreateTableStmt {
+	s.columns = append(s.columns, defs...)
+	return s
+}
+
+func (s *CreateTableStmt) Constraints(constraints ... *TableConstraintNode) *CreateTableStmt {
+	s.constraints = append(s.constraints, constraints...)
+	return s
+}
+
+// Create a partitioned table using the given partition key columns or expressions.
+func (s *CreateTableStmt) PartitionBy(method PartitionMethod, exps ... interface{}) *CreateTableStmt {
+	s.partitionBy = method
+	s.partitionKeys = getExpList(exps)
+	return s
+}
+
+func (s *CreateTableStmt) Clone() Stmt {
+	res := *s
+	res.columns = make([]*ColumnDefNode, len(s.columns))
+	for i, def := range s.columns {
+		res.columns[i] = def.clone()
+	}
+	res.constraints = make([]*TableConstraintNode, len(s.constraints))
+	for i, constraint := range s.constraints {
+		res.constraints[i] = constraint.clone()
+	}
+	res.partitionKeys = deepcopyColExpList(s.partitionKeys)
+	return &res
+}
+
+func CreateTable(table *TableNode) *CreateTableStmt {
+	return &CreateTableStmt{table: table}
+}
+
+// Alter table statement.
+type alterTableAction struct {
+	op         string
+	col        *ColumnNode
+	def        *ColumnDefNode
+	constraint *TableConstraintNode
+	name       string
+	newName    string
+	dataType   string
+	exp        ColExp
+	cascade    bool
+}
+
+const (
+	alterAddColumn        string = "ADD COLUMN"
+	alterDropColumn              = "DROP COLUMN"
+	alterColumnType              = "TYPE"
+	alterSetDefault              = "SET DEFAULT"
+	alterDropDefault             = "DROP DEFAULT"
+	alterSetNotNull              = "SET NOT NULL"
+	alterDropNotNull             = "DROP NOT NULL"
+	alterAddConstraint           = "ADD"
+	alterDropConstraint          = "DROP CONSTRAINT"
+	alterRenameColumn            = "RENAME COLUMN"
+	alterRenameConstraint        = "RENAME CONSTRAINT"
+	alterRenameTo                = "RENAME TO"
+)
+
+func (a *alterTableAction) isRename() bool {
+	return a.op == alterRenameColumn || a.op == alterRenameConstraint || a.op == alterRenameTo
+}
+
+func (a *alterTableAction) toSQL(ctx *buildContext) {
+	switch a.op {
+	case alterAddColumn:
+		ctx.buf.WriteString(a.op + " ")
+		a.def.toSQL(ctx)
+	case alterAddConstraint:
+		ctx.buf.WriteString(a.op + " ")
+		a.constraint.toSQL(ctx)
+	case alterDropColumn:
+		ctx.buf.WriteString(a.op + " " + ctx.QuoteObject(a.col.name))
+	case alterDropConstraint:
+		ctx.buf.WriteString(a.op + " " + ctx.QuoteObject(a.name))
+	case alterColumnType:
+		ctx.buf.WriteString("ALTER COLUMN " + ctx.QuoteObject(a.col.name) + " TYPE " + a.dataType)
+		if a.exp != nil {
+			ctx.buf.WriteString(" USING ")
+			a.exp.toSQL(ctx)
+		}
+	case alterSetDefault:
+		ctx.buf.WriteString("ALTER COLUMN " + ctx.QuoteObject(a.col.name) + " " + a.op + " ")
+		a.exp.toSQL(ctx)
+	case alterDropDefault, alterSetNotNull, alterDropNotNull:
+		ctx.buf.WriteString("ALTER COLUMN " + ctx.QuoteObject(a.col.name) + " " + a.op)
+	case alterRenameColumn:
+		ctx.buf.WriteString(a.op + " " + ctx.QuoteObject(a.col.name) + " TO " + ctx.QuoteObject(a.newName))
+	case alterRenameConstraint:
+		ctx.buf.WriteString(a.op + " " + ctx.QuoteObject(a.name) + " TO " + ctx.QuoteObject(a.newName))
+	case alterRenameTo:
+		ctx.buf.WriteString(a.op + " " + ctx.QuoteObject(a.newName))
+	}
+	if a.cascade {
+		ctx.buf.WriteString(" CASCADE")
+	}
+}
+
+type AlterTableStmt struct {
+	table    *TableNode
+	ifExists bool
+	actions  []*alterTableAction
+}
+
+func (s *AlterTableStmt) isStmt() {}
+
+func (s *AlterTableStmt) toSQL(ctx *buildContext) {
+	if len(s.actions) == 0 {
+		panic("must have at least one action")
+	}
+	origState := ctx.setState(buildContextStateNoColumnSource)
+	ctx.buf.WriteString("ALTER TABLE ")
+	if s.ifExists {
+		ctx.buf.WriteString("IF EXISTS ")
+	}
+	s.table.toSQL(ctx)
+	ctx.buf.WriteByte(' ')
+	for i, action := range s.actions {
+		if action.isRename() && len(s.actions) > 1 {
+			panic("RENAME cannot be combined with other actions")
+		}
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		action.toSQL(ctx)
+	}
+	ctx.setState(origState)
+}
+
+func (s *AlterTableStmt) addAction(action *alterTableAction) *AlterTableStmt {
+	s.actions = append(s.actions, action)
+	return s
+}
+
+func (s *AlterTableStmt) IfExists() *AlterTableStmt {
+	s.ifExists = true
+	return s
+}
+
+func (s *AlterTableStmt) AddColumn(def *ColumnDefNode) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterAddColumn, def: def})
+}
+
+func (s *AlterTableStmt) DropColumn(col *ColumnNode, cascade bool) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterDropColumn, col: col, cascade: cascade})
+}
+
+// Change the data type of the column; pass a non-nil using expression to convert the existing values.
+func (s *AlterTableStmt) AlterColumnType(col *ColumnNode, dataType string, using ColExp) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterColumnType, col: col, dataType: dataType, exp: using})
+}
+
+func (s *AlterTableStmt) SetDefault(col *ColumnNode, exp interface{}) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterSetDefault, col: col, exp: getExp(exp)})
+}
+
+func (s *AlterTableStmt) DropDefault(col *ColumnNode) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterDropDefault, col: col})
+}
+
+func (s *AlterTableStmt) SetNotNull(col *ColumnNode) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterSetNotNull, col: col})
+}
+
+func (s *AlterTableStmt) DropNotNull(col *ColumnNode) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterDropNotNull, col: col})
+}
+
+func (s *AlterTableStmt) AddConstraint(constraint *TableConstraintNode) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterAddConstraint, constraint: constraint})
+}
+
+func (s *AlterTableStmt) DropConstraint(name string, cascade bool) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterDropConstraint, name: name, cascade: cascade})
+}
+
+// Renames cannot be combined with other actions.
+func (s *AlterTableStmt) RenameColumn(col *ColumnNode, newName string) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterRenameColumn, col: col, newName: newName})
+}
+
+func (s *AlterTableStmt) RenameConstraint(name, newName string) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterRenameConstraint, name: name, newName: newName})
+}
+
+func (s *AlterTableStmt) RenameTo(newName string) *AlterTableStmt {
+	return s.addAction(&alterTableAction{op: alterRenameTo, newName: newName})
+}
+
+func (s *AlterTableStmt) Clone() Stmt {
+	res := *s
+	res.actions = make([]*alterTableAction, len(s.actions))
+	for i, action := range s.actions {
+		a := *action
+		if action.def != nil {
+			a.def = action.def.clone()
+		}
+		a.exp = deepcopyColExp(action.exp)
+		if action.constraint != nil {
+			a.constraint = action.constraint.clone()
+		}
+		res.actions[i] = &a
+	}
+	return &res
+}
+
+func AlterTable(table *TableNode) *AlterTableStmt {
+	return &AlterTableStmt{table: table}
+}
+
+// Drop table statement.
+type DropTableStmt struct {
+	tables   []*TableNode
+	ifExists bool
+	cascade  bool
+}
+
+func (s *DropTableStmt) isStmt() {}
+
+func (s *DropTableStmt) toSQL(ctx *buildContext) {
+	ctx.buf.WriteString("DROP TABLE ")
+	if s.ifExists {
+		ctx.buf.WriteString("IF EXISTS ")
+	}
+	for i, table := range s.tables {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		table.toSQL(ctx)
+	}
+	if s.cascade {
+		ctx.buf.WriteString(" CASCADE")
+	}
+}
+
+func (s *DropTableStmt) IfExists() *DropTableStmt {
+	s.ifExists = true
+	return s
+}
+
+func (s *DropTableStmt) Cascade() *DropTableStmt {
+	s.cascade = true
+	return s
+}
+
+func (s *DropTableStmt) Clone() Stmt {
+	res := *s
+	res.tables = append([]*TableNode{}, s.tables...)
+	return &res
+}
+
+func DropTable(tables ... *TableNode) *DropTableStmt {
+	if len(tables) == 0 {
+		panic("must have at least one table")
+	}
+	return &DropTableStmt{tables: tables}
+}
+
+// Create index statement.
+type IndexMethod string
+
+const (
+	IndexBtree  IndexMethod = "btree"
+	IndexHash               = "hash"
+	IndexGist               = "gist"
+	IndexSpgist             = "spgist"
+	IndexGin                = "gin"
+	IndexBrin               = "brin"
+)
+
+type CreateIndexStmt struct {
+	name         string
+	table        *TableNode
+	exps         []ColExp
+	unique       bool
+	concurrently bool
+	ifNotExists  bool
+	method       IndexMethod
+	include      []*ColumnNode
+	whereClause  *whereClause
+}
+
+func (s *CreateIndexStmt) isStmt() {}
+
+func (s *CreateIndexStmt) toSQL(ctx *buildContext) {
+	origState := ctx.setState(buildContextStateNoColumnSource)
+	ctx.buf.WriteString("CREATE ")
+	if s.unique {
+		ctx.buf.WriteString("UNIQUE ")
+	}
+	ctx.buf.WriteString("INDEX ")
+	if s.concurrently {
+		ctx.buf.WriteString("CONCURRENTLY ")
+	}
+	if s.ifNotExists {
+		ctx.buf.WriteString("IF NOT EXISTS ")
+	}
+	if s.name != "" {
+		ctx.buf.WriteString(ctx.QuoteObject(s.name) + " ")
+	}
+	ctx.buf.WriteString("ON ")
+	s.table.toSQL(ctx)
+	if s.method != "" {
+		ctx.buf.WriteString(" USING " + string(s.method))
+	}
+	ctx.buf.WriteString(" (")
+	indexElemsToSQL(s.exps, ctx)
+	ctx.buf.WriteByte(')')
+	if len(s.include) > 0 {
+		ctx.buf.WriteString(" INCLUDE (")
+		columnNamesToSQL(s.include, ctx)
+		ctx.buf.WriteByte(')')
+	}
+	if s.whereClause != nil {
+		ctx.buf.WriteString(" WHERE ")
+		s.whereClause.basePredicateClause.toSQL(ctx)
+	}
+	ctx.setState(origState)
+}
+
+func (s *CreateIndexStmt) Unique() *CreateIndexStmt {
+	s.unique = true
+	return s
+}
+
+func (s *CreateIndexStmt) Concurrently() *CreateIndexStmt {
+	s.concurrently = true
+	return s
+}
+
+func (s *CreateIndexStmt) IfNotExists() *CreateIndexStmt {
+	if s.name == "" {
+		panic("IF NOT EXISTS requires an index name")
+	}
+	s.ifNotExists = true
+	return s
+}
+
+func (s *CreateIndexStmt) Using(method IndexMethod) *CreateIndexStmt {
+	s.method = method
+	return s
+}
+
+// Non-key columns stored in the index (covering index).
+func (s *CreateIndexStmt) Include(cols ... *ColumnNode) *CreateIndexStmt {
+	s.include = append(s.include, cols...)
+	return s
+}
+
+// Create a partial index.
+func (s *CreateIndexStmt) Where(exps ... interface{}) *CreateIndexStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	if s.whereClause == nil {
+		s.whereClause = &whereClause{}
+	}
+	s.whereClause.addPredicate(exps...)
+	return s
+}
+
+func (s *CreateIndexStmt) Clone() Stmt {
+	res := *s
+	res.exps = deepcopyColExpList(s.exps)
+	res.include = append([]*ColumnNode{}, s.include...)
+	res.whereClause = deepcopyClause(s.whereClause).(*whereClause)
+	return &res
+}
+
+// Key elements can be columns, expressions or orderings (i.e. Desc(col)). Pass an empty name to let Postgres
+// choose one.
+func CreateIndex(name string, table *TableNode, exps ... interface{}) *CreateIndexStmt {
+	if len(exps) == 0 {
+		panic("must have at least one key element")
+	}
+	return &CreateIndexStmt{name: name, table: table, exps: getExpList(exps)}
+}
+
+// Drop index statement.
+type DropIndexStmt struct {
+	index        *TableNode
+	concurrently bool
+	ifExists     bool
+	cascade      bool
+}
+
+func (s *DropIndexStmt) isStmt() {}
+
+func (s *DropIndexStmt) toSQL(ctx *buildContext) {
+	ctx.buf.WriteString("DROP INDEX ")
+	if s.concurrently {
+		ctx.buf.WriteString("CONCURRENTLY ")
+	}
+	if s.ifExists {
+		ctx.buf.WriteString("IF EXISTS ")
+	}
+	s.index.toSQL(ctx)
+	if s.cascade {
+		ctx.buf.WriteString(" CASCADE")
+	}
+}
+
+func (s *DropIndexStmt) Concurrently() *DropIndexStmt {
+	s.concurrently = true
+	return s
+}
+
+func (s *DropIndexStmt) IfExists() *DropIndexStmt {
+	s.ifExists = true
+	return s
+}
+
+func (s *DropIndexStmt) Cascade() *DropIndexStmt {
+	s.cascade = true
+	return s
+}
+
+func (s *DropIndexStmt) Clone() Stmt {
+	res := *s
+	return &res
+}
+
+func DropIndex(schema, name string) *DropIndexStmt {
+	// Indexes share the namespace of tables.
+	return &DropIndexStmt{index: Table(schema, name)}
+}
+
+// Helper functions.
+func referencedTable(col *ColumnNode) *TableNode {
+	table, ok := col.source.(*TableNode)
+	if !ok {
+		panic("referenced column must belong to a table")
+	}
+	return table
+}
+
+func columnNamesToSQL(cols []*ColumnNode, ctx *buildContext) {
+	for i, col := range cols {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		ctx.buf.WriteString(ctx.QuoteObject(col.name))
+	}
+}
+
+// Render index / partition key elements; expressions other than function calls must be parenthesized.
+func indexElemsToSQL(exps []ColExp, ctx *buildContext) {
+	for i, exp := range exps {
+		if i > 0 {
+			ctx.buf.WriteString(", ")
+		}
+		switch exp.(type) {
+		case *ColumnNode, *FuncCallNode, *OrderExpNode:
+			exp.toSQL(ctx)
+		default:
+			ctx.buf.WriteByte('(')
+			exp.toSQL(ctx)
+			ctx.buf.WriteByte(')')
+		}
+	}
+}
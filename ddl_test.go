@@ -0,0 +1,82 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateTableStmt(t *testing.T) {
+	city := Table("public", "city")
+	cityId := Column(city, "id")
+	school := Table("public", "school")
+	id := Column(school, "id")
+	name := Column(school, "name")
+	cityIdCol := Column(school, "cityId")
+	enrollment := Column(school, "enrollment")
+	createdAt := Column(school, "createdAt")
+
+	ctx := NewContext()
+	stmt := CreateTable(school).IfNotExists().Columns(
+		ColumnDef(id, "bigserial").PrimaryKey(),
+		ColumnDef(name, "text").NotNull().Unique(),
+		ColumnDef(cityIdCol, "bigint").References(cityId),
+		ColumnDef(enrollment, "int").Default(0).NotNull().Check(enrollment.Gte(0)),
+		ColumnDef(createdAt, "timestamptz").Default(FuncCall("now")),
+	).Constraints(
+		Unique(name, cityIdCol).Named("school_name_city_key"),
+		ForeignKey(cityIdCol).References(cityId).OnDelete(RefCascade),
+	)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "public"."school" ("id" bigserial PRIMARY KEY, "name" text NOT NULL UNIQUE, "cityId" bigint REFERENCES "public"."city" ("id"), "enrollment" int DEFAULT 0 NOT NULL CHECK ("enrollment" >= 0), "createdAt" timestamptz DEFAULT now(), CONSTRAINT "school_name_city_key" UNIQUE ("name", "cityId"), FOREIGN KEY ("cityId") REFERENCES "public"."city" ("id") ON DELETE CASCADE)`,
+		stmtToSQL(ctx, stmt))
+
+	stmt = CreateTable(school).Columns(ColumnDef(id, "bigint"), ColumnDef(createdAt, "timestamptz")).
+		Constraints(PrimaryKey(id, createdAt)).PartitionBy(PartitionByRange, createdAt)
+	assert.Equal(t, `CREATE TABLE "public"."school" ("id" bigint, "createdAt" timestamptz, PRIMARY KEY ("id", "createdAt")) PARTITION BY RANGE ("createdAt")`,
+		stmtToSQL(ctx, stmt))
+}
+
+func TestAlterTableStmt(t *testing.T) {
+	school := Table("public", "school")
+	name := Column(school, "name")
+	city := Column(school, "city")
+
+	ctx := NewContext()
+	stmt := AlterTable(school).IfExists().
+		AddColumn(ColumnDef(city, "text").Default("Madison")).
+		AlterColumnType(name, "varchar(100)", nil).
+		SetNotNull(name).
+		DropConstraint("school_name_key", true).
+		AddConstraint(Check(FuncCall("length", name).Gt(0)).Named("school_name_check"))
+	assert.Equal(t, `ALTER TABLE IF EXISTS "public"."school" ADD COLUMN "city" text DEFAULT 'Madison', ALTER COLUMN "name" TYPE varchar(100), ALTER COLUMN "name" SET NOT NULL, DROP CONSTRAINT "school_name_key" CASCADE, ADD CONSTRAINT "school_name_check" CHECK (length("name") > 0)`,
+		stmtToSQL(ctx, stmt))
+
+	assert.Equal(t, `ALTER TABLE "public"."school" RENAME COLUMN "name" TO "title"`,
+		stmtToSQL(ctx, AlterTable(school).RenameColumn(name, "title")))
+	assert.Panics(t, func() {
+		stmtToSQL(ctx, AlterTable(school).RenameTo("college").DropColumn(city, false))
+	})
+}
+
+func TestDropTableStmt(t *testing.T) {
+	ctx := NewContext()
+	assert.Equal(t, `DROP TABLE IF EXISTS "public"."school", "public"."city" CASCADE`,
+		stmtToSQL(ctx, DropTable(Table("public", "school"), Table("public", "city")).IfExists().Cascade()))
+}
+
+func TestCreateIndexStmt(t *testing.T) {
+	school := Table("public", "school")
+	name := Column(school, "name")
+	city := Column(school, "city")
+	tags := Column(school, "tags")
+	deletedAt := Column(school, "deletedAt")
+
+	ctx := NewContext()
+	stmt := CreateIndex("school_name_idx", school, FuncCall("lower", name), Desc(city), city.Add(1)).
+		Unique().Concurrently().IfNotExists().Include(tags).Where(deletedAt.Is(Null))
+	assert.Equal(t, `CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS "school_name_idx" ON "public"."school" (lower("name"), "city" DESC, ("city" + 1)) INCLUDE ("tags") WHERE "deletedAt" IS NULL`,
+		stmtToSQL(ctx, stmt))
+	assert.Equal(t, `CREATE INDEX ON "public"."school" USING gin ("tags")`,
+		stmtToSQL(ctx, CreateIndex("", school, tags).Using(IndexGin)))
+	assert.Equal(t, `DROP INDEX CONCURRENTLY IF EXISTS "public"."school_name_idx"`,
+		stmtToSQL(ctx, DropIndex("public", "school_name_idx").Concurrently().IfExists()))
+}
@@ -0,0 +1,83 @@
+package pgqb
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Marks the output of ToDebugSQL.
+const debugSQLPrefix = "/* debug rendering, not for execution */ "
+
+// Render the statement with the argument values inlined as literals (i.e. WHERE "id" = 42 instead of
+// WHERE "id" = $1), for logging only. The values are given in the order of the placeholders; in
+// ContextModeNamedArgument pass a single map[string]interface{} instead. Arguments without a value keep their
+// placeholders. The output is prefixed with a comment marking it as not for execution, as the inlined values
+// (i.e. times, values of unknown types) are not guaranteed to round-trip.
+func (ctx *Context) ToDebugSQL(stmt Stmt, args ... interface{}) string {
+	bCtx := ctx.createBuildContext()
+	if bCtx.NamedArgumentMode() {
+		if len(args) > 0 {
+			named, ok := args[0].(map[string]interface{})
+			if !ok || len(args) > 1 {
+				panic("named arguments must be given as a single map[string]interface{}")
+			}
+			bCtx.namedArgValues = named
+		}
+	} else {
+		bCtx.argValues = args
+	}
+	stmt.toSQL(bCtx)
+	bCtx.trimTrailingSpace(true)
+	return debugSQLPrefix + bCtx.buf.String()
+}
+
+// Same as convertValueToLiteral, but never panics and also accepts the types database/sql drivers commonly
+// accept (i.e. driver.Valuer, time.Time, []byte, pointers and named basic types).
+func debugLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case SQLLiteral:
+		return v.GetSQLRepr()
+	case driver.Valuer:
+		if isNilPointer(v) {
+			return "NULL"
+		}
+		dv, err := v.Value()
+		if err != nil {
+			return quoteLiteral(fmt.Sprintf("<%v>", err))
+		}
+		return debugLiteral(dv)
+	case time.Time:
+		return convertValueToLiteral(v)
+	case []byte:
+		return quoteLiteral(`\x` + hex.EncodeToString(v))
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return debugLiteral(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return convertValueToLiteral(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return convertValueToLiteral(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return convertValueToLiteral(rv.Float())
+	case reflect.Bool:
+		return convertValueToLiteral(rv.Bool())
+	case reflect.String:
+		return convertValueToLiteral(rv.String())
+	}
+	return quoteLiteral(fmt.Sprint(value))
+}
+
+func isNilPointer(value interface{}) bool {
+	rv := reflect.ValueOf(value)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
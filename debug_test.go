@@ -0,0 +1,39 @@
+package pgqb
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+type debugStatus string
+
+func TestContext_ToDebugSQL(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+
+	ctx := NewContext()
+	stmt := Select(c1).Where(c3.Gt(Arg("min")), c2.Eq(Arg("city")), c1.Ne(Arg("min")), c1.Ne(Arg("")))
+	assert.Equal(t, debugSQLPrefix+`SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 42 AND "school"."city" = 'O''Fallon' AND "school"."name" != 42 AND "school"."name" != $3`,
+		ctx.ToDebugSQL(stmt, 42, "O'Fallon"))
+
+	named := NewContextWithMode(ContextModeAutoFrom | ContextModeNamedArgument)
+	assert.Equal(t, debugSQLPrefix+`SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 42 AND "school"."city" = :city`,
+		named.ToDebugSQL(Select(c1).Where(c3.Gt(Arg("min")), c2.Eq(Arg("city"))), map[string]interface{}{"min": 42}))
+	assert.Panics(t, func() {
+		named.ToDebugSQL(stmt, 42)
+	})
+
+	var nilPtr *int
+	n := 7
+	values := []interface{}{nil, nilPtr, &n, debugStatus("open"), []byte{0xde, 0xad},
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), sql.NullString{String: "x", Valid: true}, sql.NullInt64{},
+		[]int{1}}
+	expected := []string{"NULL", "NULL", "7", "'open'", `'\xdead'`, "'2020-01-02 03:04:05Z'", "'x'", "NULL", "'[1]'"}
+	for i, value := range values {
+		assert.Equal(t, expected[i], debugLiteral(value))
+	}
+}
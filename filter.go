@@ -0,0 +1,169 @@
+package pgqb
+
+// Row-level table filters (i.e. tenant_id = $1 on every tenant-scoped table).
+
+// Return a copy of the context that ANDs the predicates into the WHERE clause of every SELECT, UPDATE and DELETE
+// with the table (by schema and name) in its FROM items, subqueries included, and into the ON condition of every
+// MERGE with the table as its target or source (see applyMergeFilters). The predicates refer to the columns of the
+// table; where the table is aliased they are rebound to the alias. On the nullable side of an outer join they go
+// into the ON condition instead, so that the join stays an outer join.
+func (ctx *Context) WithTableFilter(table *TableNode, predicates ... interface{}) *Context {
+	res := *ctx
+	res.tableFilters = map[string][]ColExp{}
+	for key, filters := range ctx.tableFilters {
+		res.tableFilters[key] = filters
+	}
+	key := tableKey(table)
+	filters := res.tableFilters[key]
+	res.tableFilters[key] = append(filters[:len(filters):len(filters)], getExpList(predicates)...)
+	return &res
+}
+
+func tableKey(table *TableNode) string {
+	return table.schema + "." + table.tbname
+}
+
+// Apply the filter to the FROM (or USING) items of a statement being rendered, returning the items and the
+// WHERE clause to render instead, as rendering must not change the statement. The predicates of target (the table of
+// an UPDATE or DELETE, or nil) go first. Only the statement's own items are filtered: the outer ones a correlated
+// subquery refers to are filtered by the statement they belong to.
+func (ctx *buildContext) applyTableFilters(target *TableNode, exps []TableExp, where *whereClause,
+	filter tableFilterFunc) ([]TableExp, *whereClause) {
+	if len(ctx.tableFilters) == 0 && !hasSoftDeleteColumns() {
+		return exps, where
+	}
+	var predicates []interface{}
+	if target != nil {
+		predicates = filter(target, target)
+	}
+	if exps != nil {
+		exps = append([]TableExp{}, exps...)
+		predicates = append(predicates, filterTableExpList(exps, filter)...)
+	}
+	if len(predicates) == 0 {
+		return exps, where
+	}
+	res := &whereClause{}
+	if where != nil {
+		res.predicate = where.predicate
+	}
+	res.addPredicate(predicates...)
+	return exps, res
+}
+
+// Apply the filters to a MERGE being rendered, returning the USING items, the ON condition and the WHEN clauses
+// to render instead. The predicates of both the target and the source go into the ON condition. The rows that do
+// not match are not filtered by it, so the predicates of the source also go into the conditions of WHEN NOT
+// MATCHED, and those of the target into the ones of WHEN NOT MATCHED BY SOURCE.
+func (ctx *buildContext) applyMergeFilters(s *MergeStmt) ([]TableExp, ColExp, []*mergeWhenClause) {
+	exps := s.usingClause.tbExpList
+	if len(ctx.tableFilters) == 0 {
+		return exps, s.onExp, s.whenClauses
+	}
+	_, targetPredicates := filterTableExp(s.target, ctx.tableFilterPredicates)
+	exps = append([]TableExp{}, exps...)
+	srcPredicates := filterTableExpList(exps, ctx.tableFilterPredicates)
+	if len(targetPredicates) == 0 && len(srcPredicates) == 0 {
+		return s.usingClause.tbExpList, s.onExp, s.whenClauses
+	}
+	onExp := And(append(append([]interface{}{s.onExp}, targetPredicates...), srcPredicates...)...)
+	whens := make([]*mergeWhenClause, len(s.whenClauses))
+	for i, when := range s.whenClauses {
+		var predicates []interface{}
+		switch when.match {
+		case whenNotMatched:
+			predicates = srcPredicates
+		case whenNotMatchedBySource:
+			predicates = targetPredicates
+		}
+		if len(predicates) == 0 {
+			whens[i] = when
+			continue
+		}
+		if !isNull(when.condition) {
+			predicates = append([]interface{}{when.condition}, predicates...)
+		}
+		res := *when
+		res.condition = And(predicates...)
+		whens[i] = &res
+	}
+	return exps, onExp, whens
+}
+
+// Filters of a table referenced as src (i.e. an alias of the table).
+type tableFilterFunc func(table *TableNode, src ColSource) []interface{}
+
+// Filter the FROM items in place, returning the predicates for the WHERE clause.
+func filterTableExpList(exps []TableExp, filter tableFilterFunc) []interface{} {
+	var res []interface{}
+	for i, exp := range exps {
+		var predicates []interface{}
+		exps[i], predicates = filterTableExp(exp, filter)
+		res = append(res, predicates...)
+	}
+	return res
+}
+
+// Return the table expression with the filters of the nullable sides of its joins in their ON conditions, and the
+// filters left for the enclosing WHERE clause (or ON condition).
+func filterTableExp(exp TableExp, filter tableFilterFunc) (TableExp, []interface{}) {
+	switch n := exp.node().(type) {
+	case *TableNode:
+		return n, filter(n, n)
+	case *TableAliasNode:
+		if tb, ok := n.table.(*TableNode); ok {
+			return n, filter(tb, n)
+		}
+	case *JoinNode:
+		src, srcPredicates := filterTableExp(n.src, filter)
+		dst, dstPredicates := filterTableExp(n.dst, filter)
+		var on, where []interface{}
+		switch n.joinType {
+		case LeftOuterJoin:
+			on, where = dstPredicates, srcPredicates
+		case RightOuterJoin:
+			on, where = srcPredicates, dstPredicates
+		case FullOuterJoin:
+			on = append(srcPredicates, dstPredicates...)
+		case NaturalLeftJoin, NaturalRightJoin:
+			if len(srcPredicates) > 0 || len(dstPredicates) > 0 {
+				panic("cannot filter the tables of a natural outer join")
+			}
+		default:
+			where = append(srcPredicates, dstPredicates...)
+		}
+		if len(on) == 0 && src == n.src && dst == n.dst {
+			return n, where
+		}
+		onExp := n.exp
+		if len(on) > 0 {
+			if len(n.usingCols) > 0 {
+				panic("cannot filter the nullable side of an outer join with USING")
+			}
+			onExp = And(append([]interface{}{n.exp}, on...)...)
+		}
+		res := Join(n.joinType, src, dst, onExp)
+		res.usingCols = n.usingCols
+		return res, where
+	}
+	// Subqueries are filtered on their own.
+	return exp, nil
+}
+
+// The filters of the table, with its columns rebound to src (i.e. an alias of the table).
+func (ctx *buildContext) tableFilterPredicates(table *TableNode, src ColSource) []interface{} {
+	key := tableKey(table)
+	filters := ctx.tableFilters[key]
+	res := make([]interface{}, len(filters))
+	for i, filter := range filters {
+		res[i] = Rewrite(filter, func(node Node) Node {
+			if col, ok := node.(*ColumnNode); ok {
+				if tb, ok := col.source.(*TableNode); ok && tableKey(tb) == key {
+					return src.Column(col.name)
+				}
+			}
+			return node
+		}).(ColExp)
+	}
+	return res
+}
@@ -0,0 +1,95 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_WithTableFilter(t *testing.T) {
+	t1 := Table("public", "school")
+	t2 := Table("public", "city")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t2, "name")
+
+	base := NewContext()
+	ctx := base.WithTableFilter(t1, Column(t1, "tenant_id").Eq(Arg("tenant")))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."city" = 'Madison' AND "school"."tenant_id" = $1`,
+		stmtToSQL(ctx, Select(c1).Where(c2.Eq("Madison"))))
+	// The base context is not changed.
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school"`, stmtToSQL(base, Select(c1)))
+
+	// Aliases and subqueries.
+	s := t1.As("s")
+	stmt := Select(c3).Where(c3.In(SubQuery(Select(s.Column("city")).From(s))))
+	assert.Equal(t, `SELECT "city"."name" FROM "public"."city" WHERE "city"."name" IN (SELECT "s"."city" FROM "public"."school" "s" WHERE "s"."tenant_id" = $1 )`,
+		stmtToSQL(ctx, stmt))
+
+	// The nullable side of an outer join is filtered in the ON condition.
+	ctx = ctx.WithTableFilter(t2, Column(t2, "tenant_id").Eq(Arg("tenant")))
+	stmt = Select(c1, c3).From(Join(LeftOuterJoin, t2, t1, c2.Eq(c3)))
+	assert.Equal(t, `SELECT "school"."name", "city"."name" FROM "public"."city" LEFT OUTER JOIN "public"."school" ON ("school"."city" = "city"."name" AND "school"."tenant_id" = $1) WHERE "city"."tenant_id" = $1`,
+		stmtToSQL(ctx, stmt))
+	assert.Panics(t, func() {
+		ctx.ToSQL(Select(c1).From(JoinUsing(LeftOuterJoin, t2, t1, c3)))
+	})
+
+	assert.Equal(t, `UPDATE "public"."school" SET "name" = 'Lincoln' FROM "public"."city" WHERE "school"."city" = "city"."name" AND "school"."tenant_id" = $1 AND "city"."tenant_id" = $1`,
+		stmtToSQL(ctx, Update(t1, Set{c1: "Lincoln"}).Where(c2.Eq(c3))))
+	assert.Equal(t, `DELETE FROM "public"."school" WHERE "school"."name" = 'Lincoln' AND "school"."tenant_id" = $1`,
+		stmtToSQL(ctx, DeleteFrom(t1).Where(c1.Eq("Lincoln"))))
+}
+
+func TestContext_WithTableFilter_Merge(t *testing.T) {
+	t1 := Table("public", "school")
+	t2 := Table("public", "staging")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	src := t2.As("src")
+
+	ctx := NewContext().WithTableFilter(t1, Column(t1, "tenant_id").Eq(Arg("tenant"))).
+		WithTableFilter(t2, Column(t2, "tenant_id").Eq(Arg("tenant")))
+	stmt := MergeInto(t1).Using(src, c1.Eq(src.Column("name"))).
+		WhenMatched(MergeUpdate(Set{c2: src.Column("city")})).
+		WhenNotMatched(MergeInsert(c1, c2).Values(src.Column("name"), src.Column("city")), src.Column("city").IsNot(Null)).
+		WhenNotMatchedBySource(MergeDelete())
+	assert.Equal(t, `MERGE INTO "public"."school" USING "public"."staging" "src" ON "school"."name" = "src"."name" AND "school"."tenant_id" = $1 AND "src"."tenant_id" = $1 WHEN MATCHED THEN UPDATE SET "city" = "src"."city" WHEN NOT MATCHED AND "src"."city" IS NOT NULL AND "src"."tenant_id" = $1 THEN INSERT ("name", "city") VALUES ("src"."name", "src"."city") WHEN NOT MATCHED BY SOURCE AND "school"."tenant_id" = $1 THEN DELETE`,
+		stmtToSQL(ctx, stmt))
+	// Rendering does not change the statement.
+	assert.Equal(t, `MERGE INTO "public"."school" USING "public"."staging" "src" ON "school"."name" = "src"."name" WHEN MATCHED THEN UPDATE SET "city" = "src"."city" WHEN NOT MATCHED AND "src"."city" IS NOT NULL THEN INSERT ("name", "city") VALUES ("src"."name", "src"."city") WHEN NOT MATCHED BY SOURCE THEN DELETE`,
+		stmtToSQL(NewContext(), stmt))
+}
+
+func TestContext_WithTableFilter_Correlated(t *testing.T) {
+	t1 := Table("public", "school")
+	t2 := Table("public", "city")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t2, "name")
+
+	// The outer tables of correlated subqueries are not pulled into them, even by unrelated filters.
+	ctx := NewContext().WithTableFilter(Table("public", "other"), SQL("true"))
+	stmt := Select(c1).Where(Exists(Select(c3).From(t2).Where(c3.Eq(c2))))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE EXISTS (SELECT "city"."name" FROM "public"."city" WHERE "city"."name" = "school"."city" )`,
+		stmtToSQL(ctx, stmt))
+
+	ctx = NewContext().WithTableFilter(t1, Column(t1, "tenant_id").Eq(Arg("tenant"))).
+		WithTableFilter(t2, Column(t2, "tenant_id").Eq(Arg("tenant")))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE EXISTS (SELECT "city"."name" FROM "public"."city" WHERE "city"."name" = "school"."city" AND "city"."tenant_id" = $1 ) AND "school"."tenant_id" = $1`,
+		stmtToSQL(ctx, stmt))
+
+	top := SubQueryTableExp(Select(c1).From(t1).Where(c2.Eq(c3)).Limit(3), "top")
+	stmt = Select(c3, top.Column("name")).From(t2.LeftOuterJoin(Lateral(top), Literal(true)))
+	assert.Equal(t, `SELECT "city"."name", "top"."name" FROM "public"."city" LEFT OUTER JOIN LATERAL (SELECT "school"."name" FROM "public"."school" WHERE "school"."city" = "city"."name" AND "school"."tenant_id" = $1 LIMIT 3 ) "top" ON (true) WHERE "city"."tenant_id" = $1`,
+		stmtToSQL(ctx, stmt))
+}
+
+func TestContext_WithTableFilter_Model(t *testing.T) {
+	rest := RestaurantModel()
+	ctx := NewContext().WithTableFilter(restaurantTable, rest.OwnerId.Eq(Arg("owner")))
+	assert.Equal(t, `SELECT "Restaurant"."Name" FROM "public"."Restaurant" WHERE "Restaurant"."OwnerId" = $1`,
+		stmtToSQL(ctx, Select(rest.Name).From(rest)))
+	owner := Table("public", "owner")
+	assert.Equal(t, `SELECT "r"."Name" FROM "public"."owner" INNER JOIN "public"."Restaurant" "r" ON ("r"."OwnerId" = "owner"."id") WHERE "r"."OwnerId" = $1`,
+		stmtToSQL(ctx, Select(rest.As("r").Name).From(owner.InnerJoin(rest.As("r"), rest.As("r").OwnerId.Eq(Column(owner, "id"))))))
+}
@@ -0,0 +1,44 @@
+package pgqb
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Placeholder of the values in normalised SQL.
+const normalizedValue = "?"
+
+// Render the statement with every literal and argument replaced by ?, and every list of values on the right of
+// IN by a single (?), so that statements differing only in their values (i.e. for metrics) share the same text.
+func (ctx *Context) NormalizeSQL(stmt Stmt) string {
+	bCtx := ctx.createBuildContext()
+	bCtx.normalize = true
+	stmt.toSQL(bCtx)
+	bCtx.trimTrailingSpace(true)
+	return bCtx.buf.String()
+}
+
+// Stable hash (16 hex digits) of the normalised SQL of the statement.
+func (ctx *Context) Fingerprint(stmt Stmt) string {
+	h := fnv.New64a()
+	h.Write([]byte(ctx.NormalizeSQL(stmt)))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Whether the expression is a list of values only (i.e. (1, 2, 3) or ($1, $2)).
+func isValueList(exp ColExp) bool {
+	switch n := exp.(type) {
+	case *TupleNode:
+		return true
+	case *RowNode:
+		for _, exp := range n.expList {
+			switch exp.(type) {
+			case *LiteralNode, *ArgumentNode:
+			default:
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
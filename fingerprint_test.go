@@ -0,0 +1,33 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext_NormalizeSQL(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+
+	ctx := NewContext()
+	stmt1 := Select(c1).Where(c2.In(Tuple("Madison", "Seattle")), c3.Gt(100), c1.IsNot(Null)).Limit(10)
+	stmt2 := Select(c1).Where(c2.In(Row(Arg("a"))), c3.Gt(Arg("min")), c1.IsNot(nil)).Limit(Arg("limit"))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."city" IN (?) AND "school"."enrollment" > ? AND "school"."name" IS NOT NULL LIMIT ?`,
+		ctx.NormalizeSQL(stmt1))
+	assert.Equal(t, ctx.NormalizeSQL(stmt1), ctx.NormalizeSQL(stmt2))
+	assert.Equal(t, ctx.Fingerprint(stmt1), ctx.Fingerprint(stmt2))
+	assert.Len(t, ctx.Fingerprint(stmt1), 16)
+
+	stmt3 := Select(c1).Where(c2.In(Row(c1, "a")), c3.Gt(100), c1.Is(Null))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."city" IN ("school"."name", ?) AND "school"."enrollment" > ? AND "school"."name" IS NULL`,
+		ctx.NormalizeSQL(stmt3))
+	assert.NotEqual(t, ctx.Fingerprint(stmt1), ctx.Fingerprint(stmt3))
+
+	assert.Equal(t, `UPDATE "public"."school" SET "enrollment" = ? WHERE "school"."name" = ANY(?)`,
+		ctx.NormalizeSQL(Update(t1, Set{c3: 0}).Where(c1.Eq(SQL("ANY(?)", Array("a", "b"))))))
+	// Rendering the statement itself is not affected.
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."city" IN ('Madison', 'Seattle') AND "school"."enrollment" > 100 AND "school"."name" IS NOT NULL LIMIT 10`,
+		stmtToSQL(ctx, stmt1))
+}
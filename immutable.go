@@ -0,0 +1,174 @@
+package pgqb
+
+// Immutable select statement. Every builder method returns a new statement that shares the unchanged parts
+// with the original, so base queries can be stored in package-level variables and extended safely.
+type ImmutableSelectStmt struct {
+	stmt *SelectStmt
+}
+
+func (ImmutableSelectStmt) isStmt() {}
+
+func (s *ImmutableSelectStmt) toSQL(ctx *buildContext) {
+	s.stmt.toSQL(ctx)
+}
+
+// Nothing can change the statement, so the copy shares everything with the original.
+func (s *ImmutableSelectStmt) Clone() Stmt {
+	return &ImmutableSelectStmt{stmt: s.stmt}
+}
+
+// Return a mutable deep copy of the statement (i.e. to use it as a subquery).
+func (s *ImmutableSelectStmt) Mutable() *SelectStmt {
+	return s.stmt.Make()
+}
+
+// Shallow copy; the caller must replace (rather than modify) the clauses it changes.
+func (s *ImmutableSelectStmt) derive() *SelectStmt {
+	stmt := *s.stmt
+	return &stmt
+}
+
+func (s *ImmutableSelectStmt) Select(exps ... interface{}) *ImmutableSelectStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	stmt := s.derive()
+	stmt.selectClause = &selectClause{}
+	if s.stmt.selectClause != nil {
+		stmt.selectClause.colExpList = sharedColExpList(s.stmt.selectClause.colExpList)
+	}
+	stmt.selectClause.addColExp(exps...)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) From(exps ... TableExp) *ImmutableSelectStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	stmt := s.derive()
+	stmt.fromClause = &fromClause{}
+	if s.stmt.fromClause != nil {
+		tbExpList := s.stmt.fromClause.tbExpList
+		stmt.fromClause.tbExpList = tbExpList[:len(tbExpList):len(tbExpList)]
+	}
+	stmt.fromClause.addTableExp(exps...)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) Where(exps ... interface{}) *ImmutableSelectStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	stmt := s.derive()
+	stmt.whereClause = &whereClause{}
+	if s.stmt.whereClause != nil {
+		// The existing predicate gets wrapped rather than modified.
+		*stmt.whereClause = *s.stmt.whereClause
+	}
+	stmt.whereClause.addPredicate(exps...)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) WhereIf(cond bool, exps ... interface{}) *ImmutableSelectStmt {
+	if !cond {
+		return s
+	}
+	return s.Where(exps...)
+}
+
+// The scopes are applied to a mutable copy of the statement.
+func (s *ImmutableSelectStmt) Apply(scopes ... Scope) *ImmutableSelectStmt {
+	if len(scopes) == 0 {
+		return s
+	}
+	return &ImmutableSelectStmt{stmt: s.Mutable().Apply(scopes...)}
+}
+
+func (s *ImmutableSelectStmt) GroupBy(exps ... interface{}) *ImmutableSelectStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	stmt := s.derive()
+	stmt.groupByClause = &groupByClause{}
+	if s.stmt.groupByClause != nil {
+		stmt.groupByClause.colExpList = sharedColExpList(s.stmt.groupByClause.colExpList)
+	}
+	stmt.groupByClause.addColExp(exps...)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) Having(exps ... interface{}) *ImmutableSelectStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	stmt := s.derive()
+	stmt.havingClause = &havingClause{}
+	if s.stmt.havingClause != nil {
+		*stmt.havingClause = *s.stmt.havingClause
+	}
+	stmt.havingClause.addPredicate(exps...)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) OrderBy(exps ... interface{}) *ImmutableSelectStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	stmt := s.derive()
+	stmt.orderByClause = &orderByClause{}
+	if s.stmt.orderByClause != nil {
+		stmt.orderByClause.colExpList = sharedColExpList(s.stmt.orderByClause.colExpList)
+	}
+	stmt.orderByClause.addColExp(exps...)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) Limit(n interface{}) *ImmutableSelectStmt {
+	stmt := s.derive()
+	stmt.Limit(n)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) WithDeleted() *ImmutableSelectStmt {
+	stmt := s.derive()
+	stmt.WithDeleted()
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) LimitAll() *ImmutableSelectStmt {
+	stmt := s.derive()
+	stmt.LimitAll()
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) Offset(n interface{}) *ImmutableSelectStmt {
+	stmt := s.derive()
+	stmt.Offset(n)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) FetchFirst(n interface{}) *ImmutableSelectStmt {
+	stmt := s.derive()
+	stmt.FetchFirst(n)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+func (s *ImmutableSelectStmt) FetchFirstWithTies(n interface{}) *ImmutableSelectStmt {
+	stmt := s.derive()
+	stmt.FetchFirstWithTies(n)
+	return &ImmutableSelectStmt{stmt: stmt}
+}
+
+// Take a snapshot of the statement; later changes to it are not reflected in the result.
+func (s *SelectStmt) Immutable() *ImmutableSelectStmt {
+	return &ImmutableSelectStmt{stmt: s.Make()}
+}
+
+func ImmutableSelect(exps ... interface{}) *ImmutableSelectStmt {
+	return Select(exps...).Immutable()
+}
+
+// Limit the capacity so that appending to the result never writes to the shared backing array.
+func sharedColExpList(exps []ColExp) []ColExp {
+	return exps[:len(exps):len(exps)]
+}
@@ -0,0 +1,35 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"fmt"
+)
+
+func TestImmutableSelectStmt(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+	t2 := Table("public", "city")
+
+	ctx := NewContext()
+	base := ImmutableSelect(c1).Where(c3.Gt(100))
+	q1 := base.Where(c2.Eq("Madison")).OrderBy(c1).Limit(10)
+	q2 := base.Select(c2).Where(c2.Eq(Column(t2, "name")))
+	q3 := base.Select(c3)
+
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 100`, stmtToSQL(ctx, base))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 100 AND "school"."city" = 'Madison' ORDER BY "school"."name" ASC LIMIT 10`,
+		stmtToSQL(ctx, q1))
+	sql := stmtToSQL(ctx, q2)
+	expSQLTmpl := `SELECT "school"."name", "school"."city" FROM "public"."%s", "public"."%s" WHERE "school"."enrollment" > 100 AND "school"."city" = "city"."name"`
+	assert.True(t, sql == fmt.Sprintf(expSQLTmpl, "school", "city") || sql == fmt.Sprintf(expSQLTmpl, "city", "school"))
+	assert.Equal(t, `SELECT "school"."name", "school"."enrollment" FROM "public"."school" WHERE "school"."enrollment" > 100`, stmtToSQL(ctx, q3))
+	// Rendering with AutoFrom must not have changed the base query.
+	assert.Equal(t, `SELECT "school"."name" WHERE "school"."enrollment" > 100`, stmtToSQL(&Context{}, base))
+
+	mutable := base.Mutable()
+	mutable.Where(c2.Ne("Seattle"))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 100`, stmtToSQL(ctx, base))
+}
@@ -0,0 +1,163 @@
+package pgqb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyset (seek) pagination.
+
+// Return a copy of the statement that selects the rows after the one with the given key values, i.e. the next
+// page. There must be one value for each ORDER BY expression; to avoid skipping rows the last one should be
+// unique (i.e. the primary key) and none of the key values may be NULL. Use tagged arguments (i.e. Arg("k1")) to
+// bind the values, as a value may appear more than once when ASC and DESC are mixed; this includes the values
+// decoded from a cursor (see DecodeCursor). Values passed directly are inlined as literals.
+func (s *SelectStmt) SeekAfter(values ... interface{}) *SelectStmt {
+	return s.Make().Where(keysetPredicate(s.keysetOrder(), getExpList(values)))
+}
+
+func (s *SelectStmt) keysetOrder() []*OrderExpNode {
+	if s.orderByClause == nil || len(s.orderByClause.colExpList) == 0 {
+		panic("keyset pagination requires ORDER BY")
+	}
+	res := make([]*OrderExpNode, len(s.orderByClause.colExpList))
+	for i, exp := range s.orderByClause.colExpList {
+		// The ORDER BY clause wraps every expression in an OrderExpNode.
+		res[i] = exp.(*OrderExpNode)
+	}
+	return res
+}
+
+// A row comparison, i.e. (a, b) > ($1, $2), when all the expressions are in the same direction; otherwise
+// a > $1 OR (a = $1 AND b < $2).
+func keysetPredicate(order []*OrderExpNode, values []ColExp) ColExp {
+	if len(order) != len(values) {
+		panic("the number of values must match the number of ORDER BY expressions")
+	}
+	sameDirection := true
+	for _, exp := range order[1:] {
+		sameDirection = sameDirection && exp.descending() == order[0].descending()
+	}
+	if sameDirection {
+		if len(order) == 1 {
+			return keysetComparison(order[0].descending(), order[0].exp, values[0])
+		}
+		exps := make([]interface{}, len(order))
+		rowValues := make([]interface{}, len(values))
+		for i, exp := range order {
+			exps[i], rowValues[i] = exp.exp, values[i]
+		}
+		return keysetComparison(order[0].descending(), Row(exps...), Row(rowValues...))
+	}
+	var alternatives []interface{}
+	for i, exp := range order {
+		var conds []interface{}
+		for j := 0; j < i; j++ {
+			conds = append(conds, order[j].exp.Eq(values[j]))
+		}
+		conds = append(conds, keysetComparison(exp.descending(), exp.exp, values[i]))
+		if len(conds) == 1 {
+			alternatives = append(alternatives, conds[0])
+		} else {
+			alternatives = append(alternatives, And(conds...))
+		}
+	}
+	return Or(alternatives...)
+}
+
+// The comparison that selects the rows after the value according to the direction.
+func keysetComparison(descending bool, exp, value ColExp) ColExp {
+	if descending {
+		return exp.Lt(value)
+	}
+	return exp.Gt(value)
+}
+
+func (n *OrderExpNode) descending() bool {
+	switch n.op {
+	case orderAsc, orderUsing + "<":
+		return false
+	case orderDesc, orderUsing + ">":
+		return true
+	}
+	panic("keyset pagination only supports ordering by < or >")
+}
+
+// Encode the key values of the last row of a page into an opaque cursor. Only scalars (numbers, strings,
+// booleans and times) are supported.
+func EncodeCursor(values ... interface{}) (string, error) {
+	encoded := make([]interface{}, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, string, bool:
+			encoded[i] = v
+		case time.Time:
+			encoded[i] = cursorTime{v}
+		default:
+			return "", fmt.Errorf("unsupported cursor value type %T", value)
+		}
+	}
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Times are wrapped to tell them apart from strings.
+type cursorTime struct {
+	Time time.Time `json:"t"`
+}
+
+// Decode a cursor created by EncodeCursor. Integers are returned as int64 (uint64 when they do not fit), other
+// numbers as float64, times as time.Time and strings and booleans as they are. As the cursor comes from the
+// client, bind the values as arguments (see SeekAfter) rather than trusting them.
+func DecodeCursor(cursor string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values []interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, errInvalidCursor
+	}
+	for i, value := range values {
+		switch v := value.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				values[i] = n
+			} else if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+				values[i] = n
+			} else if f, err := v.Float64(); err == nil && strings.ContainsAny(v.String(), ".eE") {
+				// Integers out of range are rejected rather than rounded.
+				values[i] = f
+			} else {
+				return nil, errInvalidCursor
+			}
+		case string, bool:
+		case map[string]interface{}:
+			s, ok := v["t"].(string)
+			if !ok || len(v) != 1 {
+				return nil, errInvalidCursor
+			}
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, errInvalidCursor
+			}
+			values[i] = t
+		default:
+			return nil, errInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
@@ -0,0 +1,75 @@
+package pgqb
+
+import (
+	"encoding/base64"
+	"math"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectStmt_SeekAfter(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "id")
+	c2 := Column(t1, "name")
+	c3 := Column(t1, "enrollment")
+
+	ctx := NewContext()
+	stmt := Select(c2).OrderBy(Asc(c3), Asc(c1)).Limit(20)
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE ("school"."enrollment", "school"."id") > ($1, $2) ORDER BY "school"."enrollment" ASC, "school"."id" ASC LIMIT 20`,
+		stmtToSQL(ctx, stmt.SeekAfter(Arg("k1"), Arg("k2"))))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" ASC, "school"."id" ASC LIMIT 20`,
+		stmtToSQL(ctx, stmt))
+
+	stmt = Select(c2).Where(c2.Ne("")).OrderBy(Desc(c3), Asc(c1))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."name" != '' AND ("school"."enrollment" < $1 OR "school"."enrollment" = $1 AND "school"."id" > $2) ORDER BY "school"."enrollment" DESC, "school"."id" ASC`,
+		stmtToSQL(ctx, stmt.SeekAfter(Arg("k1"), Arg("k2"))))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."id" < 10 ORDER BY "school"."id" DESC`,
+		stmtToSQL(ctx, Select(c2).OrderBy(Desc(c1)).SeekAfter(10)))
+
+	assert.Equal(t, `SELECT "school"."id" FROM "public"."school" WHERE ("school"."name" COLLATE "C", "school"."id") < ($1, $2) ORDER BY "school"."name" COLLATE "C" USING >, "school"."id" DESC`,
+		stmtToSQL(ctx, Select(c1).OrderBy(OrderUsing(c2, ">").Collate("C"), Desc(c1)).SeekAfter(Arg("k1"), Arg("k2"))))
+
+	assert.Panics(t, func() {
+		Select(c2).SeekAfter(1)
+	})
+	assert.Panics(t, func() {
+		Select(c2).OrderBy(OrderUsing(c1, "~<~")).SeekAfter(1)
+	})
+	assert.Panics(t, func() {
+		Select(c2).OrderBy(Asc(c1)).SeekAfter(1, 2)
+	})
+}
+
+func TestCursor(t *testing.T) {
+	created := time.Date(2020, 5, 1, 12, 30, 0, 500, time.UTC)
+	cursor, err := EncodeCursor(1200, 1.5, created, "Lincoln", true, uint64(math.MaxUint64))
+	assert.Nil(t, err)
+	values, err := DecodeCursor(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{int64(1200), 1.5, created, "Lincoln", true, uint64(math.MaxUint64)}, values)
+
+	// Times and strings that look like times are told apart.
+	cursor, err = EncodeCursor(created.Format(time.RFC3339Nano))
+	assert.Nil(t, err)
+	values, err = DecodeCursor(cursor)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"2020-05-01T12:30:00.0000005Z"}, values)
+
+	t1 := Table("public", "school")
+	stmt := Select(Column(t1, "name")).OrderBy(Column(t1, "enrollment"), Column(t1, "created"), Column(t1, "name")).
+		SeekAfter(Arg("k1"), Arg("k2"), Arg("k3"))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE ("school"."enrollment", "school"."created", "school"."name") > ($1, $2, $3) ORDER BY "school"."enrollment" ASC, "school"."created" ASC, "school"."name" ASC`,
+		stmtToSQL(NewContext(), stmt))
+
+	_, err = DecodeCursor("not a cursor")
+	assert.NotNil(t, err)
+	_, err = EncodeCursor(nil)
+	assert.NotNil(t, err)
+	_, err = EncodeCursor([]int{1})
+	assert.NotNil(t, err)
+	for _, data := range []string{`[null]`, `[{"a": 1}]`, `[{"t": 1}]`, `[[1]]`, `[100000000000000000000]`} {
+		_, err = DecodeCursor(base64.RawURLEncoding.EncodeToString([]byte(data)))
+		assert.NotNil(t, err, data)
+	}
+}
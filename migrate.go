@@ -0,0 +1,316 @@
+package pgqb
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DDL statements that reconcile a database with the desired tables.
+type Migration struct {
+	// Statements that never lose data, in execution order.
+	Stmts []Stmt
+	// Statements that may lose data (i.e. dropping tables / columns, narrowing column types).
+	Destructive []Stmt
+}
+
+// Return the statements to execute; destructive ones run last and only when explicitly allowed.
+func (m *Migration) All(allowDestructive bool) []Stmt {
+	res := append([]Stmt{}, m.Stmts...)
+	if allowDestructive {
+		res = append(res, m.Destructive...)
+	}
+	return res
+}
+
+func (m *Migration) Empty() bool {
+	return len(m.Stmts) == 0 && len(m.Destructive) == 0
+}
+
+// Compare the desired tables against the ones introspected by GetAllTables. As the introspection only reports
+// columns, constraints are only created along with new tables. Column types are compared along with their
+// length, precision and scale and, for arrays, their element types; narrowing any of them is destructive.
+// Other modifiers (i.e. timestamp(3)) are ignored. Only the tables under the schemas of the desired tables
+// may be dropped.
+func DiffSchema(desired []*CreateTableStmt, actual map[string][]*columns) *Migration {
+	res := &Migration{}
+	desiredNames := map[string]bool{}
+	schemas := map[string]bool{}
+	var newTables []*CreateTableStmt
+	for _, table := range desired {
+		fullName := qualifiedTableName(table.table)
+		desiredNames[fullName] = true
+		schemas[tableSchema(table.table)] = true
+		if actualCols, in := actual[fullName]; in {
+			safe, destructive := diffColumns(table, actualCols)
+			if safe != nil {
+				res.Stmts = append(res.Stmts, safe)
+			}
+			if destructive != nil {
+				res.Destructive = append(res.Destructive, destructive)
+			}
+		} else {
+			newTables = append(newTables, table)
+		}
+	}
+	// Create the new tables before the existing ones are altered, as new columns may refer to them.
+	var createStmts []Stmt
+	for _, table := range sortTablesByDependency(newTables) {
+		createStmts = append(createStmts, table)
+	}
+	res.Stmts = append(createStmts, res.Stmts...)
+
+	var droppedNames []string
+	for fullName, cols := range actual {
+		if len(cols) == 0 || desiredNames[fullName] || !schemas[cols[0].TableSchema] {
+			continue
+		}
+		droppedNames = append(droppedNames, fullName)
+	}
+	sort.Strings(droppedNames)
+	var dropped []*TableNode
+	for _, fullName := range droppedNames {
+		col := actual[fullName][0]
+		dropped = append(dropped, Table(col.TableSchema, col.TableName))
+	}
+	if len(dropped) > 0 {
+		res.Destructive = append(res.Destructive, DropTable(dropped...))
+	}
+	return res
+}
+
+func diffColumns(table *CreateTableStmt, actualCols []*columns) (safe, destructive *AlterTableStmt) {
+	safe = AlterTable(table.table)
+	destructive = AlterTable(table.table)
+	actualByName := make(map[string]*columns, len(actualCols))
+	for _, col := range actualCols {
+		actualByName[col.ColumnName] = col
+	}
+	desiredNames := map[string]bool{}
+	for _, def := range table.columns {
+		desiredNames[def.col.name] = true
+		actualCol, in := actualByName[def.col.name]
+		if !in {
+			safe.AddColumn(def)
+			continue
+		}
+		fromType := actualColumnType(actualCol)
+		toType := desiredColumnType(def.dataType)
+		if fromType != toType && fromType.dataType != "USER-DEFINED" {
+			if isTypeWidening(fromType, toType) {
+				safe.AlterColumnType(def.col, def.dataType, nil)
+			} else {
+				destructive.AlterColumnType(def.col, def.dataType, SQL("?::"+def.dataType, def.col))
+			}
+		}
+		notNull := def.notNull || def.primaryKey
+		if notNull && actualCol.IsNullable {
+			safe.SetNotNull(def.col)
+		} else if !notNull && !actualCol.IsNullable {
+			safe.DropNotNull(def.col)
+		}
+	}
+	for _, col := range actualCols {
+		if !desiredNames[col.ColumnName] {
+			destructive.DropColumn(Column(table.table, col.ColumnName), false)
+		}
+	}
+	if len(safe.actions) == 0 {
+		safe = nil
+	}
+	if len(destructive.actions) == 0 {
+		destructive = nil
+	}
+	return
+}
+
+// Order the tables so that referenced tables are created first.
+func sortTablesByDependency(tables []*CreateTableStmt) []*CreateTableStmt {
+	byName := make(map[string]*CreateTableStmt, len(tables))
+	for _, table := range tables {
+		byName[qualifiedTableName(table.table)] = table
+	}
+	var res []*CreateTableStmt
+	visited := map[string]bool{}
+	var visit func(table *CreateTableStmt)
+	visit = func(table *CreateTableStmt) {
+		name := qualifiedTableName(table.table)
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, ref := range table.referencedTables() {
+			if dep, in := byName[qualifiedTableName(ref)]; in {
+				visit(dep)
+			}
+		}
+		res = append(res, table)
+	}
+	for _, table := range tables {
+		visit(table)
+	}
+	return res
+}
+
+func (s *CreateTableStmt) referencedTables() []*TableNode {
+	var res []*TableNode
+	for _, def := range s.columns {
+		if def.references != nil {
+			res = append(res, referencedTable(def.references))
+		}
+	}
+	for _, constraint := range s.constraints {
+		if len(constraint.refCols) > 0 {
+			res = append(res, referencedTable(constraint.refCols[0]))
+		}
+	}
+	return res
+}
+
+// Helper functions.
+func tableSchema(table *TableNode) string {
+	if table.schema == "" {
+		return "public"
+	}
+	return table.schema
+}
+
+// Same format as the keys returned by GetAllTables.
+func qualifiedTableName(table *TableNode) string {
+	return tableSchema(table) + "." + table.tbname
+}
+
+// Column type conversions that never lose data.
+var typeWidenings = map[[2]string]bool{
+	{"smallint", "integer"}:            true,
+	{"smallint", "bigint"}:             true,
+	{"smallint", "numeric"}:            true,
+	{"integer", "bigint"}:              true,
+	{"integer", "numeric"}:             true,
+	{"bigint", "numeric"}:              true,
+	{"real", "double precision"}:       true,
+	{"character", "character varying"}: true,
+	{"character", "text"}:              true,
+	{"character varying", "text"}:      true,
+}
+
+// Aliases of the type names reported by information_schema.columns.data_type.
+var dataTypeAliases = map[string]string{
+	"int":         "integer",
+	"int4":        "integer",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"int8":        "bigint",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"int2":        "smallint",
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"varchar":     "character varying",
+	"char":        "character",
+	"bpchar":      "character",
+	"bool":        "boolean",
+	"float4":      "real",
+	"float8":      "double precision",
+	"float":       "double precision",
+	"decimal":     "numeric",
+	"timestamptz": "timestamp with time zone",
+	"timestamp":   "timestamp without time zone",
+	"timetz":      "time with time zone",
+	"time":        "time without time zone",
+	"varbit":      "bit varying",
+}
+
+var typeModifiers = regexp.MustCompile(`\s*\([^)]*\)`)
+
+var typeModifierArgs = regexp.MustCompile(`\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+
+var arrayBounds = regexp.MustCompile(`(\s*\[\d*\])+$`)
+
+// Number of decimal digits of the integer types.
+var integerDigits = map[string]int{
+	"smallint": 5,
+	"integer":  10,
+	"bigint":   19,
+}
+
+// Column type along with the modifiers that bound its values; zero modifiers are unspecified.
+type columnType struct {
+	dataType string
+	// Element type of arrays.
+	elemType  string
+	length    int
+	precision int
+	scale     int
+}
+
+func actualColumnType(col *columns) columnType {
+	res := columnType{dataType: col.DataType}
+	switch col.DataType {
+	case "character", "character varying":
+		res.length = col.CharMaxLength
+	case "numeric":
+		res.precision, res.scale = col.NumericPrec, col.NumericScale
+	case "ARRAY":
+		res.elemType = normalizeDataType(strings.TrimPrefix(col.UdtName, "_"))
+	}
+	return res
+}
+
+func desiredColumnType(dataType string) columnType {
+	res := columnType{dataType: normalizeDataType(dataType)}
+	switch res.dataType {
+	case "character", "character varying":
+		if m := typeModifierArgs.FindStringSubmatch(dataType); m != nil {
+			res.length, _ = strconv.Atoi(m[1])
+		} else if res.dataType == "character" {
+			// character without a length is character(1).
+			res.length = 1
+		}
+	case "numeric":
+		if m := typeModifierArgs.FindStringSubmatch(dataType); m != nil {
+			res.precision, _ = strconv.Atoi(m[1])
+			res.scale, _ = strconv.Atoi(m[2])
+		}
+	case "ARRAY":
+		res.elemType = normalizeDataType(arrayBounds.ReplaceAllString(strings.TrimSpace(dataType), ""))
+	}
+	return res
+}
+
+// Whether converting a column from one type to the other never loses data.
+func isTypeWidening(from, to columnType) bool {
+	if from.dataType != to.dataType && !typeWidenings[[2]string{from.dataType, to.dataType}] {
+		return false
+	}
+	switch to.dataType {
+	case "ARRAY":
+		return from.elemType == to.elemType || typeWidenings[[2]string{from.elemType, to.elemType}]
+	case "character", "character varying":
+		return to.length == 0 || from.length != 0 && from.length <= to.length
+	case "numeric":
+		if to.precision == 0 {
+			return true
+		}
+		fromPrecision, fromScale := from.precision, from.scale
+		if digits, in := integerDigits[from.dataType]; in {
+			fromPrecision = digits
+		}
+		return fromPrecision != 0 && fromScale <= to.scale && fromPrecision-fromScale <= to.precision-to.scale
+	}
+	return true
+}
+
+// Convert a type name to the form reported by information_schema.columns.data_type.
+func normalizeDataType(dataType string) string {
+	dataType = strings.ToLower(strings.TrimSpace(dataType))
+	if strings.HasSuffix(dataType, "]") {
+		return "ARRAY"
+	}
+	dataType = strings.Join(strings.Fields(typeModifiers.ReplaceAllString(dataType, "")), " ")
+	if alias, in := dataTypeAliases[dataType]; in {
+		return alias
+	}
+	return dataType
+}
@@ -0,0 +1,103 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSchema(t *testing.T) {
+	city := Table("public", "city")
+	school := Table("public", "school")
+	cityId := Column(city, "id")
+	desired := []*CreateTableStmt{
+		CreateTable(school).Columns(
+			ColumnDef(Column(school, "id"), "bigserial").PrimaryKey(),
+			ColumnDef(Column(school, "name"), "varchar(100)").NotNull(),
+			ColumnDef(Column(school, "enrollment"), "bigint"),
+			ColumnDef(Column(school, "rank"), "smallint"),
+			ColumnDef(Column(school, "cityId"), "bigint").References(cityId),
+		),
+		CreateTable(city).Columns(ColumnDef(cityId, "bigserial").PrimaryKey()),
+	}
+	actual := map[string][]*columns{
+		"public.school": {
+			{TableSchema: "public", TableName: "school", ColumnName: "id", DataType: "bigint"},
+			{TableSchema: "public", TableName: "school", ColumnName: "name", DataType: "text", IsNullable: true},
+			{TableSchema: "public", TableName: "school", ColumnName: "enrollment", DataType: "integer", IsNullable: true},
+			{TableSchema: "public", TableName: "school", ColumnName: "rank", DataType: "integer", IsNullable: true},
+			{TableSchema: "public", TableName: "school", ColumnName: "state", DataType: "text", IsNullable: true},
+		},
+		"public.legacy": {
+			{TableSchema: "public", TableName: "legacy", ColumnName: "id", DataType: "integer"},
+		},
+		"audit.log": {
+			{TableSchema: "audit", TableName: "log", ColumnName: "id", DataType: "integer"},
+		},
+	}
+
+	ctx := NewContext()
+	m := DiffSchema(desired, actual)
+	assert.Len(t, m.Stmts, 2)
+	assert.Equal(t, `CREATE TABLE "public"."city" ("id" bigserial PRIMARY KEY)`, stmtToSQL(ctx, m.Stmts[0]))
+	assert.Equal(t, `ALTER TABLE "public"."school" ALTER COLUMN "name" SET NOT NULL, ALTER COLUMN "enrollment" TYPE bigint, ADD COLUMN "cityId" bigint REFERENCES "public"."city" ("id")`,
+		stmtToSQL(ctx, m.Stmts[1]))
+	assert.Len(t, m.Destructive, 2)
+	assert.Equal(t, `ALTER TABLE "public"."school" ALTER COLUMN "name" TYPE varchar(100) USING "name"::varchar(100), ALTER COLUMN "rank" TYPE smallint USING "rank"::smallint, DROP COLUMN "state"`,
+		stmtToSQL(ctx, m.Destructive[0]))
+	assert.Equal(t, `DROP TABLE "public"."legacy"`, stmtToSQL(ctx, m.Destructive[1]))
+	assert.Len(t, m.All(false), 2)
+	assert.Len(t, m.All(true), 4)
+
+	assert.True(t, DiffSchema(desired[1:], map[string][]*columns{
+		"public.city": {{TableSchema: "public", TableName: "city", ColumnName: "id", DataType: "bigint"}},
+	}).Empty())
+}
+
+func TestDiffSchema_TypeModifiers(t *testing.T) {
+	school := Table("public", "school")
+	desired := []*CreateTableStmt{
+		CreateTable(school).Columns(
+			ColumnDef(Column(school, "name"), "varchar(50)"),
+			ColumnDef(Column(school, "city"), "varchar(200)"),
+			ColumnDef(Column(school, "code"), "char(4)"),
+			ColumnDef(Column(school, "fee"), "numeric(5,2)"),
+			ColumnDef(Column(school, "rate"), "numeric(12, 4)"),
+			ColumnDef(Column(school, "budget"), "numeric(12,2)"),
+			ColumnDef(Column(school, "grades"), "int[]"),
+			ColumnDef(Column(school, "tags"), "text[]"),
+			ColumnDef(Column(school, "ids"), "bigint[]"),
+		),
+	}
+	actual := map[string][]*columns{
+		"public.school": {
+			{ColumnName: "name", DataType: "character varying", CharMaxLength: 100, UdtName: "varchar", IsNullable: true},
+			{ColumnName: "city", DataType: "character varying", CharMaxLength: 100, UdtName: "varchar", IsNullable: true},
+			{ColumnName: "code", DataType: "character", CharMaxLength: 4, UdtName: "bpchar", IsNullable: true},
+			{ColumnName: "fee", DataType: "numeric", NumericPrec: 10, NumericScale: 2, UdtName: "numeric", IsNullable: true},
+			{ColumnName: "rate", DataType: "numeric", NumericPrec: 10, NumericScale: 2, UdtName: "numeric", IsNullable: true},
+			{ColumnName: "budget", DataType: "integer", NumericPrec: 32, UdtName: "int4", IsNullable: true},
+			{ColumnName: "grades", DataType: "ARRAY", UdtName: "_int4", IsNullable: true},
+			{ColumnName: "tags", DataType: "ARRAY", UdtName: "_int4", IsNullable: true},
+			{ColumnName: "ids", DataType: "ARRAY", UdtName: "_int4", IsNullable: true},
+		},
+	}
+
+	ctx := NewContext()
+	m := DiffSchema(desired, actual)
+	if assert.Len(t, m.Stmts, 1) {
+		assert.Equal(t, `ALTER TABLE "public"."school" ALTER COLUMN "city" TYPE varchar(200), ALTER COLUMN "rate" TYPE numeric(12, 4), ALTER COLUMN "budget" TYPE numeric(12,2), ALTER COLUMN "ids" TYPE bigint[]`,
+			stmtToSQL(ctx, m.Stmts[0]))
+	}
+	if assert.Len(t, m.Destructive, 1) {
+		assert.Equal(t, `ALTER TABLE "public"."school" ALTER COLUMN "name" TYPE varchar(50) USING "name"::varchar(50), ALTER COLUMN "fee" TYPE numeric(5,2) USING "fee"::numeric(5,2), ALTER COLUMN "tags" TYPE text[] USING "tags"::text[]`,
+			stmtToSQL(ctx, m.Destructive[0]))
+	}
+}
+
+func TestNormalizeDataType(t *testing.T) {
+	assert.Equal(t, "character varying", normalizeDataType("VARCHAR (20)"))
+	assert.Equal(t, "timestamp with time zone", normalizeDataType("timestamp(3) with time zone"))
+	assert.Equal(t, "timestamp with time zone", normalizeDataType("timestamptz"))
+	assert.Equal(t, "ARRAY", normalizeDataType("int[]"))
+	assert.Equal(t, "jsonb", normalizeDataType("jsonb"))
+}
@@ -60,7 +60,7 @@ func TestModel(t *testing.T) {
 	restA := RestaurantModel()
 	restB := RestaurantModel().As("RestaurantB")
 	cnode := restA.NumCustomer.Add(restB.NumCustomer).Gt(50)
-	assert.Equal(t, `("Restaurant"."NumCustomer" + "RestaurantB"."NumCustomer") > 50`, AstToSQL(cnode))
+	assert.Equal(t, `"Restaurant"."NumCustomer" + "RestaurantB"."NumCustomer" > 50`, AstToSQL(cnode))
 
 	tnode := restA.InnerJoin(restB, restA.Name.Eq(restB.Name))
 	assert.Equal(t, `"public"."Restaurant" INNER JOIN "public"."Restaurant" "RestaurantB" ON ("Restaurant"."Name" = "RestaurantB"."Name")`,AstToSQL(tnode))
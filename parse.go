@@ -0,0 +1,1299 @@
+package pgqb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parser for the subset of Postgres SQL that pgqb can represent, i.e. for migrating hand-written queries.
+
+// Parse a SELECT, INSERT, UPDATE or DELETE statement into the same nodes the builder creates, so that it can be
+// modified with the builder and rendered again. Columns are bound to the FROM items by their qualifiers (i.e.
+// "s"."name" to "public"."school" "s"); qualifiers not in FROM refer to tables by name, and unqualified columns
+// are only accepted when there is a single FROM item. Parentheses are kept (i.e. as GroupExpNode), and positional
+// arguments become arguments tagged with their numbers (i.e. $2 is Arg("2")), named ones (i.e. :id) arguments
+// tagged with their names. As the builder numbers the arguments in the order they appear, positional ones must be
+// numbered that way as well: UPDATE t SET a = $2 WHERE id = $1 is reported as an error, so renumber them (and
+// their values) or use named arguments. Unsupported syntax (i.e. CASE, window functions, UNION) is reported as an
+// error.
+func Parse(sql string) (stmt Stmt, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr, ok := r.(*parseError)
+			if !ok {
+				panic(r)
+			}
+			stmt, err = nil, perr
+		}
+	}()
+	p := &parser{tokens: lex(sql)}
+	stmt = p.parseStmt()
+	p.acceptOp(";")
+	if p.peek().kind != tokenEOF {
+		p.fail("unexpected " + p.peek().String())
+	}
+	p.checkArgNumbers(stmt)
+	return stmt, nil
+}
+
+type parseError struct {
+	msg string
+	pos int
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.msg, e.pos)
+}
+
+// Lexer.
+
+type tokenKind int8
+
+const (
+	tokenEOF tokenKind = iota
+	// Keyword or unquoted identifier.
+	tokenIdent
+	tokenQuotedIdent
+	tokenNumber
+	// String literal, including the quotes.
+	tokenString
+	// Positional argument (i.e. $1), without the $.
+	tokenParam
+	// Named argument (i.e. :id), without the colon.
+	tokenNamedParam
+	// Operator or punctuation.
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenQuotedIdent:
+		return strconv.Quote(t.text)
+	case tokenParam:
+		return "$" + t.text
+	case tokenNamedParam:
+		return ":" + t.text
+	}
+	return `"` + t.text + `"`
+}
+
+// Operators, longest first.
+var lexOps = []string{
+	"!~*", "->>", "#>>",
+	"::", "<>", "!=", ">=", "<=", "<<", ">>", "||", "&&", "@>", "<@", "~*", "!~", "->", "#>",
+	"+", "-", "*", "/", "%", "^", "<", ">", "=", "~", "&", "|", "#", "@", "!", "(", ")", "[", "]", ",", ".", ";", ":",
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lex(sql string) []token {
+	var tokens []token
+	fail := func(msg string, pos int) {
+		panic(&parseError{msg: msg, pos: pos})
+	}
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				fail("unterminated comment", start)
+			}
+			i += end + 4
+			continue
+		case isIdentStart(c):
+			for i < len(sql) && isIdentChar(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: sql[start:i], pos: start})
+		case c == '"':
+			var name strings.Builder
+			for i++; ; i++ {
+				if i >= len(sql) {
+					fail("unterminated quoted identifier", start)
+				}
+				if sql[i] == '"' {
+					if i+1 < len(sql) && sql[i+1] == '"' {
+						i++
+					} else {
+						break
+					}
+				}
+				name.WriteByte(sql[i])
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenQuotedIdent, text: name.String(), pos: start})
+		case c == '\'':
+			for i++; ; i++ {
+				if i >= len(sql) {
+					fail("unterminated string", start)
+				}
+				if sql[i] == '\'' {
+					if i+1 < len(sql) && sql[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: sql[start:i], pos: start})
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+				i++
+			}
+			if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+				i++
+				if i < len(sql) && (sql[i] == '+' || sql[i] == '-') {
+					i++
+				}
+				for i < len(sql) && isDigit(sql[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: sql[start:i], pos: start})
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			for i++; i < len(sql) && isDigit(sql[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenParam, text: sql[start+1:i], pos: start})
+		case c == ':' && i+1 < len(sql) && isIdentStart(sql[i+1]):
+			for i++; i < len(sql) && isIdentChar(sql[i]); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenNamedParam, text: sql[start+1:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range lexOps {
+				if strings.HasPrefix(sql[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				fail(fmt.Sprintf("unexpected character %q", c), start)
+			}
+			i += len(op)
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(sql)})
+}
+
+// Parser.
+
+// Keywords that cannot be used as unquoted aliases.
+var reservedWords = map[string]bool{
+	"all": true, "and": true, "any": true, "array": true, "as": true, "asc": true, "by": true, "case": true,
+	"cast": true, "collate": true, "cross": true, "default": true, "delete": true, "desc": true, "distinct": true,
+	"do": true, "else": true, "end": true, "except": true, "exists": true, "false": true, "fetch": true,
+	"filter": true, "for": true, "from": true, "full": true, "group": true, "having": true, "in": true,
+	"inner": true, "insert": true, "intersect": true, "into": true, "is": true, "join": true, "lateral": true,
+	"left": true, "like": true, "limit": true, "natural": true, "not": true, "null": true, "nulls": true,
+	"offset": true, "on": true, "or": true, "order": true, "outer": true, "over": true, "returning": true,
+	"right": true, "row": true, "select": true, "set": true, "similar": true, "some": true, "then": true,
+	"true": true, "union": true, "update": true, "using": true, "values": true, "when": true, "where": true,
+	"window": true, "with": true, "within": true,
+}
+
+// Value keywords that look like columns (i.e. CURRENT_TIMESTAMP).
+var sqlValueFunctions = map[string]bool{
+	"current_date": true, "current_time": true, "current_timestamp": true, "localtime": true,
+	"localtimestamp": true, "current_user": true, "session_user": true,
+}
+
+// Operators other than AND, OR and the keyword ones, by their text.
+var parseOps = map[string]string{
+	"<>": opNe,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	scope  *parseScope
+}
+
+// Column sources of a statement; subqueries can refer to the ones of the enclosing statements.
+type parseScope struct {
+	parent  *parseScope
+	sources map[string]ColSource
+	// The FROM items in order, for binding unqualified columns.
+	items []ColSource
+	// Output column aliases, which unqualified names in ORDER BY refer to first.
+	aliases map[string]*ColExpAliasNode
+}
+
+func (p *parser) fail(msg string) {
+	panic(&parseError{msg: msg, pos: p.peek().pos})
+}
+
+func (p *parser) peek() token {
+	return p.peekAt(0)
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) isOp(op string) bool {
+	return t.kind == tokenOp && t.text == op
+}
+
+// Consume the keywords if the next tokens are these keywords.
+func (p *parser) acceptKeyword(keywords ... string) bool {
+	for i, keyword := range keywords {
+		if !p.peekAt(i).isKeyword(keyword) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *parser) expectKeyword(keywords ... string) {
+	if !p.acceptKeyword(keywords...) {
+		p.fail("expected " + strings.Join(keywords, " ") + ", found " + p.peek().String())
+	}
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if p.peek().isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) {
+	if !p.acceptOp(op) {
+		p.fail("expected " + op + ", found " + p.peek().String())
+	}
+}
+
+// Position of the keyword in the current statement (i.e. not in parentheses), or -1.
+func (p *parser) findKeyword(keyword string) int {
+	depth := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		switch {
+		case tok.kind == tokenEOF || (depth == 0 && tok.isOp(";")):
+			return -1
+		case tok.isOp("(") || tok.isOp("["):
+			depth++
+		case tok.isOp(")") || tok.isOp("]"):
+			if depth--; depth < 0 {
+				return -1
+			}
+		case depth == 0 && tok.isKeyword(keyword):
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *parser) isIdent(tok token) bool {
+	return tok.kind == tokenQuotedIdent || (tok.kind == tokenIdent && !reservedWords[strings.ToLower(tok.text)])
+}
+
+// Unquoted identifiers are folded to lower case, like Postgres does.
+func (p *parser) ident() string {
+	tok := p.peek()
+	if !p.isIdent(tok) {
+		p.fail("expected an identifier, found " + tok.String())
+	}
+	p.pos++
+	if tok.kind == tokenQuotedIdent {
+		return tok.text
+	}
+	return strings.ToLower(tok.text)
+}
+
+func (p *parser) identList() []string {
+	var res []string
+	p.expectOp("(")
+	for {
+		res = append(res, p.ident())
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	p.expectOp(")")
+	return res
+}
+
+// Optional alias, with or without AS.
+func (p *parser) alias() string {
+	if p.acceptKeyword("AS") || p.isIdent(p.peek()) {
+		return p.ident()
+	}
+	return ""
+}
+
+func (p *parser) pushScope() {
+	p.scope = &parseScope{parent: p.scope, sources: map[string]ColSource{}}
+}
+
+func (p *parser) popScope() {
+	p.scope = p.scope.parent
+}
+
+func (p *parser) addSource(src ColSource) {
+	p.scope.sources[src.name()] = src
+	p.scope.items = append(p.scope.items, src)
+}
+
+func (p *parser) source(name string) ColSource {
+	for scope := p.scope; scope != nil; scope = scope.parent {
+		if src, in := scope.sources[name]; in {
+			return src
+		}
+	}
+	if name == "excluded" {
+		return excludedColSource{}
+	}
+	// Not in FROM (i.e. rendered without ContextModeAutoFrom).
+	src := Table("", name)
+	p.scope.sources[name] = src
+	return src
+}
+
+// Bind an unqualified column to the only FROM item of the innermost statement that has any.
+func (p *parser) column(name string) ColExp {
+	if alias, in := p.scope.aliases[name]; in {
+		return alias
+	}
+	for scope := p.scope; scope != nil; scope = scope.parent {
+		switch len(scope.items) {
+		case 0:
+			continue
+		case 1:
+			return scope.items[0].Column(name)
+		}
+		p.fail("ambiguous unqualified column " + strconv.Quote(name))
+	}
+	p.fail("unqualified column " + strconv.Quote(name) + " without a FROM item")
+	return nil
+}
+
+// Statements.
+
+func (p *parser) parseStmt() Stmt {
+	tok := p.peek()
+	switch {
+	case tok.isKeyword("SELECT"):
+		return p.parseSelect()
+	case tok.isKeyword("INSERT"):
+		return p.parseInsert()
+	case tok.isKeyword("UPDATE"):
+		return p.parseUpdate()
+	case tok.isKeyword("DELETE"):
+		return p.parseDelete()
+	}
+	p.fail("expected SELECT, INSERT, UPDATE or DELETE, found " + tok.String())
+	return nil
+}
+
+func (p *parser) parseSelect() *SelectStmt {
+	p.expectKeyword("SELECT")
+	if p.peek().isKeyword("DISTINCT") {
+		p.fail("SELECT DISTINCT is not supported")
+	}
+	p.pushScope()
+	defer p.popScope()
+	stmt := Select()
+	var items []interface{}
+	// The FROM items go first, so that the columns can be bound to them.
+	if from := p.findKeyword("FROM"); from >= 0 {
+		listStart := p.pos
+		p.pos = from + 1
+		stmt.From(p.parseTableExpList()...)
+		end := p.pos
+		p.pos = listStart
+		items = p.parseColExpList(true)
+		if p.pos != from {
+			p.fail("unexpected " + p.peek().String())
+		}
+		p.pos = end
+	} else {
+		items = p.parseColExpList(true)
+	}
+	stmt.Select(items...)
+	if p.acceptKeyword("WHERE") {
+		stmt.Where(p.parsePredicates()...)
+	}
+	if p.acceptKeyword("GROUP", "BY") {
+		stmt.GroupBy(p.parseGroupByList()...)
+	}
+	if p.acceptKeyword("HAVING") {
+		stmt.Having(p.parsePredicates()...)
+	}
+	if p.acceptKeyword("ORDER", "BY") {
+		p.scope.aliases = map[string]*ColExpAliasNode{}
+		for _, item := range items {
+			if alias, ok := item.(*ColExpAliasNode); ok {
+				p.scope.aliases[alias.alias] = alias
+			}
+		}
+		stmt.OrderBy(p.parseOrderByList()...)
+		p.scope.aliases = nil
+	}
+	for {
+		switch {
+		case p.acceptKeyword("LIMIT"):
+			if p.acceptKeyword("ALL") {
+				stmt.LimitAll()
+			} else {
+				stmt.Limit(p.parseExp(0))
+			}
+		case p.acceptKeyword("OFFSET"):
+			stmt.Offset(p.parseExp(0))
+			if !p.acceptKeyword("ROWS") {
+				p.acceptKeyword("ROW")
+			}
+		case p.acceptKeyword("FETCH"):
+			if !p.acceptKeyword("FIRST") {
+				p.expectKeyword("NEXT")
+			}
+			n := p.parseExp(precUnaryMinus)
+			if !p.acceptKeyword("ROWS") {
+				p.expectKeyword("ROW")
+			}
+			if p.acceptKeyword("WITH", "TIES") {
+				stmt.FetchFirstWithTies(n)
+			} else {
+				p.expectKeyword("ONLY")
+				stmt.FetchFirst(n)
+			}
+		default:
+			return stmt
+		}
+	}
+}
+
+func (p *parser) parseInsert() *InsertStmt {
+	p.expectKeyword("INSERT", "INTO")
+	table := p.parseTableName()
+	p.pushScope()
+	defer p.popScope()
+	p.addSource(table)
+	var cols []*ColumnNode
+	if p.peek().isOp("(") {
+		for _, name := range p.identList() {
+			cols = append(cols, table.Column(name))
+		}
+	}
+	stmt := InsertInto(table, cols...)
+	switch {
+	case p.acceptKeyword("DEFAULT", "VALUES"):
+		stmt.DefaultValues()
+	case p.acceptKeyword("VALUES"):
+		stmt.valuesClause = &valuesClause{valuesList: p.parseValuesList()}
+	case p.peek().isKeyword("SELECT"):
+		stmt.From(p.parseSelect())
+	default:
+		p.fail("expected VALUES, DEFAULT VALUES or SELECT, found " + p.peek().String())
+	}
+	if p.acceptKeyword("ON", "CONFLICT") {
+		var target *ConflictTarget
+		switch {
+		case p.acceptKeyword("ON", "CONSTRAINT"):
+			target = OnConstraint(p.ident())
+		case p.acceptOp("("):
+			var exps []interface{}
+			for {
+				exps = append(exps, ungroup(p.parseExp(0)))
+				if !p.acceptOp(",") {
+					break
+				}
+			}
+			p.expectOp(")")
+			target = ConflictExp(exps...)
+			if p.acceptKeyword("WHERE") {
+				target.Where(p.parsePredicates()...)
+			}
+		}
+		p.expectKeyword("DO")
+		var action *conflictClause
+		if p.acceptKeyword("NOTHING") {
+			action = DoNothing()
+		} else {
+			p.expectKeyword("UPDATE")
+			action = &conflictClause{setClause: p.parseSetClause(table)}
+			if p.acceptKeyword("WHERE") {
+				action.Where(p.parsePredicates()...)
+			}
+		}
+		stmt.On(target, action)
+	}
+	if p.acceptKeyword("RETURNING") {
+		stmt.Returning(p.parseColExpList(true)...)
+	}
+	return stmt
+}
+
+func (p *parser) parseUpdate() *UpdateStmt {
+	p.expectKeyword("UPDATE")
+	table := p.parseTableName()
+	if !p.peek().isKeyword("SET") {
+		p.fail("table aliases are not supported in UPDATE")
+	}
+	p.pushScope()
+	defer p.popScope()
+	p.addSource(table)
+	stmt := Update(table, nil)
+	// Same as SELECT, the FROM items go first.
+	if from := p.findKeyword("FROM"); from >= 0 {
+		setStart := p.pos
+		p.pos = from + 1
+		stmt.From(p.parseTableExpList()...)
+		end := p.pos
+		p.pos = setStart
+		stmt.setClause = p.parseSetClause(table)
+		if p.pos != from {
+			p.fail("unexpected " + p.peek().String())
+		}
+		p.pos = end
+	} else {
+		stmt.setClause = p.parseSetClause(table)
+	}
+	if p.acceptKeyword("WHERE") {
+		stmt.Where(p.parsePredicates()...)
+	}
+	if p.acceptKeyword("RETURNING") {
+		stmt.Returning(p.parseColExpList(true)...)
+	}
+	return stmt
+}
+
+func (p *parser) parseDelete() *DeleteStmt {
+	p.expectKeyword("DELETE", "FROM")
+	table := p.parseTableName()
+	if p.isIdent(p.peek()) || p.peek().isKeyword("AS") {
+		p.fail("table aliases are not supported in DELETE")
+	}
+	p.pushScope()
+	defer p.popScope()
+	p.addSource(table)
+	stmt := DeleteFrom(table)
+	if p.acceptKeyword("USING") {
+		stmt.Using(p.parseTableExpList()...)
+	}
+	if p.acceptKeyword("WHERE") {
+		stmt.Where(p.parsePredicates()...)
+	}
+	if p.acceptKeyword("RETURNING") {
+		stmt.Returning(p.parseColExpList(true)...)
+	}
+	return stmt
+}
+
+// SET assignments in order (Set would sort them).
+func (p *parser) parseSetClause(table *TableNode) *setClause {
+	p.expectKeyword("SET")
+	res := &setClause{}
+	for {
+		var target SetTarget
+		if p.peek().isOp("(") {
+			var cols []*ColumnNode
+			for _, name := range p.identList() {
+				cols = append(cols, table.Column(name))
+			}
+			target = Columns(cols...)
+		} else {
+			var exp ColExp = table.Column(p.ident())
+			for p.peek().isOp("[") {
+				exp = p.parseSubscript(exp)
+			}
+			target = exp.(SetTarget)
+		}
+		p.expectOp("=")
+		res.set(target, p.parseExp(0))
+		if !p.acceptOp(",") {
+			return res
+		}
+	}
+}
+
+// Table expressions.
+
+func (p *parser) parseTableName() *TableNode {
+	name := p.ident()
+	if p.acceptOp(".") {
+		return Table(name, p.ident())
+	}
+	return Table("", name)
+}
+
+func (p *parser) parseTableExpList() []TableExp {
+	var res []TableExp
+	for {
+		res = append(res, p.parseJoinedTable())
+		if !p.acceptOp(",") {
+			return res
+		}
+	}
+}
+
+func (p *parser) parseJoinedTable() TableExp {
+	left := p.parseTableRef()
+	for {
+		joinType, ok := p.parseJoinType()
+		if !ok {
+			return left
+		}
+		right := p.parseTableRef()
+		switch {
+		case joinType.isUnconditional():
+			left = Join(joinType, left, right, nil)
+		case p.acceptKeyword("ON"):
+			left = Join(joinType, left, right, ungroup(p.parseExp(0)))
+		case p.acceptKeyword("USING"):
+			src, ok := right.(ColSource)
+			if !ok {
+				p.fail("USING is only supported when joining a single table")
+			}
+			var cols []*ColumnNode
+			for _, name := range p.identList() {
+				cols = append(cols, src.Column(name))
+			}
+			left = JoinUsing(joinType, left, right, cols...)
+		default:
+			p.fail("expected ON or USING, found " + p.peek().String())
+		}
+	}
+}
+
+func (p *parser) parseJoinType() (JoinType, bool) {
+	switch {
+	case p.acceptKeyword("JOIN"), p.acceptKeyword("INNER", "JOIN"):
+		return InnerJoin, true
+	case p.acceptKeyword("LEFT", "JOIN"), p.acceptKeyword("LEFT", "OUTER", "JOIN"):
+		return LeftOuterJoin, true
+	case p.acceptKeyword("RIGHT", "JOIN"), p.acceptKeyword("RIGHT", "OUTER", "JOIN"):
+		return RightOuterJoin, true
+	case p.acceptKeyword("FULL", "JOIN"), p.acceptKeyword("FULL", "OUTER", "JOIN"):
+		return FullOuterJoin, true
+	case p.acceptKeyword("CROSS", "JOIN"):
+		return CrossJoin, true
+	case p.acceptKeyword("NATURAL", "JOIN"), p.acceptKeyword("NATURAL", "INNER", "JOIN"):
+		return NaturalJoin, true
+	case p.acceptKeyword("NATURAL", "LEFT", "JOIN"), p.acceptKeyword("NATURAL", "LEFT", "OUTER", "JOIN"):
+		return NaturalLeftJoin, true
+	case p.acceptKeyword("NATURAL", "RIGHT", "JOIN"), p.acceptKeyword("NATURAL", "RIGHT", "OUTER", "JOIN"):
+		return NaturalRightJoin, true
+	}
+	return "", false
+}
+
+func (p *parser) parseTableRef() TableExp {
+	if p.acceptKeyword("LATERAL") {
+		if !p.peek().isOp("(") && !p.peekAt(1).isOp("(") && !p.peekAt(3).isOp("(") {
+			p.fail("expected a subquery or a function after LATERAL")
+		}
+		return Lateral(p.parseTableRef())
+	}
+	if p.acceptOp("(") {
+		switch {
+		case p.peek().isKeyword("SELECT"):
+			stmt := p.parseSelect()
+			p.expectOp(")")
+			alias := p.alias()
+			if alias == "" {
+				p.fail("a subquery in FROM must have an alias")
+			}
+			res := SubQueryTableExp(stmt, alias)
+			p.addSource(res)
+			return res
+		case p.acceptKeyword("VALUES"):
+			valuesList := p.parseValuesList()
+			p.expectOp(")")
+			alias := p.alias()
+			if alias == "" {
+				p.fail("a VALUES list in FROM must have an alias")
+			}
+			var cnames []string
+			if p.peek().isOp("(") {
+				cnames = p.identList()
+			}
+			res := ValuesTable(alias, cnames...)
+			res.valuesList = valuesList
+			p.addSource(res)
+			return res
+		}
+		res := p.parseJoinedTable()
+		p.expectOp(")")
+		return res
+	}
+	start := p.pos
+	name := p.ident()
+	if p.acceptOp(".") {
+		name += "." + p.ident()
+	}
+	if p.peek().isOp("(") {
+		p.pos = start
+		fn := p.parseFuncCall()
+		ordinality := p.acceptKeyword("WITH", "ORDINALITY")
+		alias := p.alias()
+		if alias == "" {
+			alias = fn.name
+		}
+		res := TableFunc(fn, alias)
+		if ordinality {
+			res.WithOrdinality()
+		}
+		if p.peek().isOp("(") {
+			res.ColumnAliases(p.identList()...)
+		}
+		p.addSource(res)
+		return res
+	}
+	p.pos = start
+	table := p.parseTableName()
+	if alias := p.alias(); alias != "" {
+		res := table.As(alias)
+		p.addSource(res)
+		return res
+	}
+	p.addSource(table)
+	return table
+}
+
+func (p *parser) parseValuesList() [][]ColExp {
+	var res [][]ColExp
+	for {
+		p.expectOp("(")
+		var values []ColExp
+		for {
+			values = append(values, p.parseExp(0))
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		p.expectOp(")")
+		res = append(res, values)
+		if !p.acceptOp(",") {
+			return res
+		}
+	}
+}
+
+// Column expression lists.
+
+// Comma-separated expressions, with aliases if aliased (i.e. the SELECT list).
+func (p *parser) parseColExpList(aliased bool) []interface{} {
+	var res []interface{}
+	for {
+		var exp ColExp
+		if p.acceptOp("*") {
+			exp = SQL("*")
+		} else {
+			exp = p.parseExp(0)
+		}
+		if aliased {
+			if alias := p.alias(); alias != "" {
+				exp = ColumnAlias(exp, alias)
+			}
+		}
+		res = append(res, exp)
+		if !p.acceptOp(",") {
+			return res
+		}
+	}
+}
+
+// Split the top-level AND, so that later calls of Where add to the same list.
+func (p *parser) parsePredicates() []interface{} {
+	exp := p.parseExp(0)
+	if logical, ok := exp.(*LogicalExpNode); ok && logical.op == opAnd {
+		res := make([]interface{}, len(logical.expList))
+		for i, exp := range logical.expList {
+			res[i] = exp
+		}
+		return res
+	}
+	return []interface{}{exp}
+}
+
+func (p *parser) parseGroupByList() []interface{} {
+	var res []interface{}
+	for {
+		var op string
+		switch {
+		case p.acceptKeyword("ROLLUP"):
+			op = opRollup
+		case p.acceptKeyword("CUBE"):
+			op = opCube
+		case p.acceptKeyword("GROUPING", "SETS"):
+			op = opGroupingSets
+		}
+		if op == "" {
+			res = append(res, p.parseExp(0))
+		} else {
+			var sets []interface{}
+			p.expectOp("(")
+			for {
+				if p.acceptOp("(") {
+					set := []interface{}{}
+					if !p.acceptOp(")") {
+						set = p.parseColExpList(false)
+						p.expectOp(")")
+					}
+					sets = append(sets, set)
+				} else {
+					sets = append(sets, p.parseExp(0))
+				}
+				if !p.acceptOp(",") {
+					break
+				}
+			}
+			p.expectOp(")")
+			res = append(res, GroupingSetsExp(op, sets))
+		}
+		if !p.acceptOp(",") {
+			return res
+		}
+	}
+}
+
+func (p *parser) parseOrderByList() []interface{} {
+	var res []interface{}
+	for {
+		exp := p.parseExp(0)
+		var order *OrderExpNode
+		switch {
+		case p.acceptKeyword("DESC"):
+			order = Desc(exp)
+		case p.acceptKeyword("USING"):
+			tok := p.next()
+			if tok.kind != tokenOp || !isOperator(tok.text) {
+				p.fail("expected an operator after USING")
+			}
+			order = OrderUsing(exp, tok.text)
+		default:
+			p.acceptKeyword("ASC")
+			order = Asc(exp)
+		}
+		switch {
+		case p.acceptKeyword("NULLS", "FIRST"):
+			order.NullsFirst()
+		case p.acceptKeyword("NULLS", "LAST"):
+			order.NullsLast()
+		}
+		res = append(res, order)
+		if !p.acceptOp(",") {
+			return res
+		}
+	}
+}
+
+// Expressions.
+
+// The operator at the current position, its precedence and its number of tokens.
+func (p *parser) peekBinaryOp() (string, int, int) {
+	tok := p.peek()
+	if tok.kind == tokenOp {
+		op := tok.text
+		if canonical, in := parseOps[op]; in {
+			op = canonical
+		}
+		if prec, in := binaryOpPrecedence[op]; in {
+			return op, prec, 1
+		}
+		return "", 0, 0
+	}
+	keywordOps := []struct {
+		keywords []string
+		op       string
+		prec     int
+	}{
+		{[]string{"OR"}, opOr, precOr},
+		{[]string{"AND"}, opAnd, precAnd},
+		{[]string{"IS", "NOT"}, opIsNot, precIs},
+		{[]string{"IS"}, opIs, precIs},
+		{[]string{"LIKE"}, opLike, precLike},
+		{[]string{"NOT", "LIKE"}, opNotLike, precLike},
+		{[]string{"SIMILAR", "TO"}, opSimilar, precLike},
+		{[]string{"NOT", "SIMILAR", "TO"}, opNotSimilar, precLike},
+		{[]string{"IN"}, opIn, precLike},
+		{[]string{"NOT", "IN"}, opNotIn, precLike},
+		{[]string{"COLLATE"}, "COLLATE", precCollate},
+	}
+	for _, keywordOp := range keywordOps {
+		match := true
+		for i, keyword := range keywordOp.keywords {
+			match = match && p.peekAt(i).isKeyword(keyword)
+		}
+		if match {
+			return keywordOp.op, keywordOp.prec, len(keywordOp.keywords)
+		}
+	}
+	return "", 0, 0
+}
+
+// Parse an expression whose operators bind at least as tightly as minPrec.
+func (p *parser) parseExp(minPrec int) ColExp {
+	left := p.parseUnary()
+	for {
+		op, prec, n := p.peekBinaryOp()
+		if n == 0 || prec < minPrec {
+			return left
+		}
+		p.pos += n
+		switch op {
+		case opAnd, opOr:
+			exps := []ColExp{left, p.parseExp(prec + 1)}
+			for p.peekAt(0).isKeyword(op) {
+				p.pos++
+				exps = append(exps, p.parseExp(prec+1))
+			}
+			left = LogicalExp(op, exps)
+		case "COLLATE":
+			left = Collate(left, p.ident())
+		case opIn, opNotIn:
+			left = BinaryExp(left, op, p.parseInList())
+		default:
+			left = BinaryExp(left, op, p.parseExp(prec+1))
+		}
+	}
+}
+
+func (p *parser) parseUnary() ColExp {
+	switch {
+	case p.acceptKeyword("NOT"):
+		return Not(p.parseExp(precNot))
+	case p.acceptOp("-"):
+		return Neg(p.parseExp(precUnaryMinus))
+	case p.acceptOp("@"):
+		return Abs(p.parseExp(precUnaryMinus))
+	}
+	return p.parsePostfix(p.parsePrimary())
+}
+
+// Subscripts and casts.
+func (p *parser) parsePostfix(exp ColExp) ColExp {
+	for {
+		switch {
+		case p.peek().isOp("["):
+			exp = p.parseSubscript(ungroup(exp))
+		case p.acceptOp("::"):
+			exp = SQL("?::"+p.parseTypeName(), exp)
+		default:
+			return exp
+		}
+	}
+}
+
+func (p *parser) parseSubscript(exp ColExp) *SubscriptNode {
+	p.expectOp("[")
+	index := p.parseExp(0)
+	var res *SubscriptNode
+	if p.acceptOp(":") {
+		res = Slice(exp, index, p.parseExp(0))
+	} else {
+		res = Subscript(exp, index)
+	}
+	p.expectOp("]")
+	return res
+}
+
+// Type name of a cast (i.e. int, varchar(10), text[], double precision).
+func (p *parser) parseTypeName() string {
+	tok := p.next()
+	if tok.kind != tokenIdent && tok.kind != tokenQuotedIdent {
+		p.fail("expected a type name, found " + tok.String())
+	}
+	name := tok.text
+	if tok.kind == tokenQuotedIdent {
+		name = `"` + strings.Replace(tok.text, `"`, `""`, -1) + `"`
+	}
+	for {
+		next := p.peek()
+		switch {
+		case next.kind == tokenIdent && !reservedWords[strings.ToLower(next.text)]:
+			name += " " + p.next().text
+		case next.isKeyword("WITH") && p.peekAt(1).isKeyword("TIME"):
+			name += " " + p.next().text
+		case next.isOp("("):
+			p.next()
+			var mods []string
+			for {
+				mods = append(mods, p.next().text)
+				if !p.acceptOp(",") {
+					break
+				}
+			}
+			p.expectOp(")")
+			name += "(" + strings.Join(mods, ", ") + ")"
+		case next.isOp("[") && p.peekAt(1).isOp("]"):
+			p.pos += 2
+			name += "[]"
+		default:
+			return name
+		}
+	}
+}
+
+func (p *parser) parsePrimary() ColExp {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenNumber, tokenString:
+		p.pos++
+		return rawLiteral(tok.text)
+	case tokenParam:
+		p.pos++
+		return Arg(tok.text)
+	case tokenNamedParam:
+		p.pos++
+		return Arg(tok.text)
+	case tokenOp:
+		if p.acceptOp("(") {
+			if p.peek().isKeyword("SELECT") {
+				stmt := p.parseSelect()
+				p.expectOp(")")
+				return SubQuery(stmt)
+			}
+			exps := p.parseColExpList(false)
+			p.expectOp(")")
+			if len(exps) == 1 {
+				return Group(exps[0].(ColExp))
+			}
+			return Row(exps...)
+		}
+		p.fail("unexpected " + tok.String())
+	case tokenQuotedIdent:
+		return p.parseColumnRef()
+	case tokenEOF:
+		p.fail("unexpected end of input")
+	}
+	keyword := strings.ToLower(tok.text)
+	switch {
+	case keyword == "null":
+		p.pos++
+		return Literal(nil)
+	case keyword == "true" || keyword == "false":
+		p.pos++
+		return rawLiteral(tok.text)
+	case keyword == "default":
+		p.pos++
+		return Default
+	case keyword == "exists" || keyword == "all" || keyword == "some" || keyword == "any":
+		if !p.peekAt(2).isKeyword("SELECT") {
+			if keyword == "any" || keyword == "some" {
+				return p.parseFuncCall()
+			}
+			p.fail(strings.ToUpper(keyword) + " requires a subquery")
+		}
+		p.pos += 2
+		stmt := p.parseSelect()
+		p.expectOp(")")
+		return SubQueryExp(strings.ToUpper(keyword), stmt)
+	case keyword == "array":
+		p.pos++
+		p.expectOp("[")
+		node := &ArrayNode{}
+		for !p.acceptOp("]") {
+			if len(node.values) > 0 {
+				p.expectOp(",")
+			}
+			value, ok := p.parseExp(0).(*LiteralNode)
+			if !ok {
+				p.fail("only arrays of literals are supported")
+			}
+			node.values = append(node.values, value.value)
+		}
+		node.ColExp = node
+		return node
+	case keyword == "row":
+		p.pos++
+		p.expectOp("(")
+		var exps []interface{}
+		if !p.acceptOp(")") {
+			exps = p.parseColExpList(false)
+			p.expectOp(")")
+		}
+		return Row(exps...)
+	case keyword == "cast":
+		p.pos++
+		p.expectOp("(")
+		exp := p.parseExp(0)
+		p.expectKeyword("AS")
+		typeName := p.parseTypeName()
+		p.expectOp(")")
+		return SQL("CAST(? AS "+typeName+")", exp)
+	case sqlValueFunctions[keyword]:
+		p.pos++
+		return SQL(strings.ToUpper(keyword))
+	case reservedWords[keyword] && !p.peekAt(1).isOp("("):
+		// Function names may be keywords (i.e. left(s, 3)).
+		p.fail("unexpected " + tok.String())
+	}
+	if p.peekAt(1).isOp("(") || (p.peekAt(1).isOp(".") && p.peekAt(3).isOp("(")) {
+		return p.parseFuncCall()
+	}
+	return p.parseColumnRef()
+}
+
+// Column (i.e. "s"."name", name, "public"."school"."name") or all the columns (i.e. "s".*).
+func (p *parser) parseColumnRef() ColExp {
+	names := []string{p.ident()}
+	for p.acceptOp(".") {
+		if p.acceptOp("*") {
+			return Star(p.source(names[len(names)-1]))
+		}
+		names = append(names, p.ident())
+	}
+	switch len(names) {
+	case 1:
+		return p.column(names[0])
+	case 2, 3:
+		return p.source(names[len(names)-2]).Column(names[len(names)-1])
+	}
+	p.fail("invalid column reference " + strconv.Quote(strings.Join(names, ".")))
+	return nil
+}
+
+func (p *parser) parseFuncCall() *FuncCallNode {
+	name := p.next().text
+	if p.acceptOp(".") {
+		name += "." + p.next().text
+	}
+	p.expectOp("(")
+	fn := FuncCall(name)
+	distinct := p.acceptKeyword("DISTINCT")
+	if !p.peek().isOp(")") && !p.peek().isKeyword("ORDER") {
+		fn.expList = getExpList(p.parseColExpList(false))
+	} else if distinct {
+		p.fail("DISTINCT requires at least one argument")
+	}
+	if distinct {
+		fn.Distinct()
+	}
+	if p.acceptKeyword("ORDER", "BY") {
+		fn.OrderBy(p.parseOrderByList()...)
+	}
+	p.expectOp(")")
+	if p.acceptKeyword("WITHIN", "GROUP") {
+		p.expectOp("(")
+		p.expectKeyword("ORDER", "BY")
+		fn.WithinGroup(p.parseOrderByList()...)
+		p.expectOp(")")
+	}
+	if p.acceptKeyword("FILTER") {
+		p.expectOp("(")
+		p.expectKeyword("WHERE")
+		fn.Filter(p.parsePredicates()...)
+		p.expectOp(")")
+	}
+	if p.peek().isKeyword("OVER") {
+		p.fail("window functions are not supported")
+	}
+	return fn
+}
+
+// Right operand of IN: a subquery, a list of literals (as a Tuple) or a list of expressions.
+func (p *parser) parseInList() ColExp {
+	p.expectOp("(")
+	if p.peek().isKeyword("SELECT") {
+		stmt := p.parseSelect()
+		p.expectOp(")")
+		return SubQuery(stmt)
+	}
+	exps := p.parseColExpList(false)
+	p.expectOp(")")
+	tuple := &TupleNode{}
+	for _, exp := range exps {
+		literal, ok := exp.(*LiteralNode)
+		if !ok {
+			tuple = nil
+			break
+		}
+		tuple.values = append(tuple.values, literal.value)
+	}
+	switch {
+	case tuple != nil:
+		tuple.ColExp = tuple
+		return tuple
+	case len(exps) == 1:
+		return Group(exps[0].(ColExp))
+	}
+	return Row(exps...)
+}
+
+// Literal in its SQL form (i.e. 'it''s', 1.50).
+func rawLiteral(sql string) *LiteralNode {
+	node := &LiteralNode{value: sql}
+	node.ColExp = node
+	return node
+}
+
+// Remove the parentheses the renderer adds itself (i.e. around ON conditions).
+func ungroup(exp ColExp) ColExp {
+	if group, ok := exp.(*GroupExpNode); ok {
+		return group.exp
+	}
+	return exp
+}
+
+// Arguments are numbered in the order they are rendered, so the positional ones must already be in that order.
+func (p *parser) checkArgNumbers(stmt Stmt) {
+	positional, named := false, false
+	for _, tok := range p.tokens {
+		positional = positional || tok.kind == tokenParam
+		named = named || tok.kind == tokenNamedParam
+	}
+	if positional && named {
+		panic(&parseError{msg: "positional and named arguments cannot be mixed", pos: 0})
+	}
+	if !positional {
+		return
+	}
+	ctx := newBuildContext(ContextModeNone)
+	stmt.toSQL(ctx)
+	for tag, argNum := range ctx.namedArgNum {
+		if strconv.Itoa(argNum) != tag {
+			panic(&parseError{msg: "arguments must be numbered in the order they appear", pos: 0})
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	ctx := NewContext()
+	// Round trips of the statements rendered in the other tests.
+	for _, sql := range []string{
+		`SELECT "school"."name", "school"."city" FROM "public"."school" WHERE "school"."city" != 'New York City'`,
+		`SELECT "school"."name", "city"."name" "city", "city"."state" FROM "public"."school", "public"."city"`,
+		`SELECT "school"."name", "city"."name" "city", "city"."state" FROM "public"."school" INNER JOIN "public"."city" ON ("school"."city" = "city"."name")`,
+		`SELECT EXISTS (SELECT "city"."name" = "school"."city" FROM "public"."city" ) FROM "public"."school"`,
+		`SELECT max("school"."enrollment") "maxEnrollment", "school"."name", "city"."state" FROM "public"."school" INNER JOIN "public"."city" ON ("school"."city" = "city"."name") GROUP BY "city"."state" HAVING max("school"."enrollment") > 1000`,
+		`SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" DESC, "school"."name" ASC LIMIT 30`,
+		`SELECT "school"."name" FROM "public"."school" LIMIT $1 OFFSET $2`,
+		`SELECT "school"."name" FROM "public"."school" LIMIT ALL`,
+		`SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" DESC OFFSET 20 FETCH FIRST 10 ROWS WITH TIES`,
+		`SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" DESC OFFSET 20 FETCH FIRST ($1 + 1) ROWS ONLY`,
+		`SELECT "city"."name", "top"."name" FROM "public"."city" LEFT OUTER JOIN LATERAL (SELECT "school"."name" FROM "public"."school" WHERE "school"."city" = "city"."name" ORDER BY "school"."enrollment" DESC LIMIT 3 ) "top" ON (true)`,
+		`SELECT "sales"."region", "sales"."city", GROUPING("sales"."region", "sales"."city"), sum("sales"."amount") FROM "public"."sales" GROUP BY "sales"."amount" > 0, ROLLUP ("sales"."region", "sales"."city")`,
+		`SELECT 1, 2, 3`,
+		`SELECT "we""ird"."a""b" FROM "public"."we""ird"`,
+		`SELECT "school"."name"::"my""type" FROM "public"."school"`,
+		`SELECT count("school"."name") FROM "public"."school" WHERE "school"."city" IN (SELECT "city"."name" FROM "public"."city" )`,
+		`SELECT "school"."name", "v"."city" FROM "public"."school" INNER JOIN (VALUES (1, 'Austin'), (2, 'Boston')) "v"("id", "city") ON ("school"."id" = "v"."id")`,
+		`INSERT INTO "public"."school" ("name", "city") VALUES ('Abc', $1)`,
+		`INSERT INTO "public"."school" DEFAULT VALUES RETURNING "school"."id"`,
+		`INSERT INTO "public"."school" ("name") VALUES ((1, 2)) ON CONFLICT ("name") DO UPDATE SET "city" = 'Seattle'`,
+		`UPDATE "public"."school" SET "city" = 'Madison' WHERE "school"."enrollment" > 50000 AND "school"."name" != 'University of Wisconsin' RETURNING "school".*`,
+		`UPDATE "public"."school" SET "enrollment" = DEFAULT, "meta"['id'] = $1, "tags"[1] = 'public', ("name", "city") = ('Abc', DEFAULT)`,
+		`UPDATE "public"."school" SET ("city", "state") = (SELECT "city"."name", "city"."state" FROM "public"."city" WHERE "city"."id" = "school"."cityId" )`,
+		`UPDATE "public"."school" SET "city" = "v"."city" FROM (VALUES (1, 'Austin'), (2, 'Boston')) "v"("id", "city") WHERE "school"."id" = "v"."id"`,
+		`DELETE FROM "public"."school" USING "public"."school" "school2" WHERE "school2"."enrollment" > 40000 AND "school"."city" = "school"."city" AND "school"."enrollment" <= 40000`,
+		`DELETE FROM "public"."school" USING "public"."city" WHERE "city"."state" = $1 AND "city"."name" = "school"."city" RETURNING "school"."name", "school"."enrollment" > 40000`,
+	} {
+		stmt, err := Parse(sql)
+		if assert.NoError(t, err, sql) {
+			assert.Equal(t, sql, stmtToSQL(ctx, stmt))
+		}
+	}
+	stmt, err := Parse(`SELECT "school"."name" WHERE "school"."enrollment" > 100`)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT "school"."name" WHERE "school"."enrollment" > 100`, stmtToSQL(&Context{}, stmt))
+
+	// Hand-written SQL: unqualified columns, keywords in any case, comments and named arguments.
+	stmt, err = Parse(`select name, count(*) as n -- per city
+		from school s where s.enrollment::int > :min and not (city like 'M%') group by 1 order by n desc nulls last;`)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT "s"."name", count(*) "n" FROM "school" "s" WHERE "s"."enrollment"::int > $1 AND NOT ("s"."city" LIKE 'M%') GROUP BY 1 ORDER BY "n" DESC NULLS LAST`,
+		stmtToSQL(ctx, stmt))
+
+	// Keywords as function names, and expressions in LIMIT and OFFSET.
+	stmt, err = Parse(`SELECT left(name, 3), right(name, 2) FROM school LIMIT $1 + 1 OFFSET $2 * 10`)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT left("school"."name", 3), right("school"."name", 2) FROM "school" LIMIT $1 + 1 OFFSET $2 * 10`,
+		stmtToSQL(ctx, stmt))
+}
+
+func TestParse_Modify(t *testing.T) {
+	stmt, err := Parse(`SELECT s.name FROM public.school s WHERE s.city = $1`)
+	assert.NoError(t, err)
+	s := Table("public", "school").As("s")
+	sel := stmt.(*SelectStmt).Where(s.Column("enrollment").Gt(100)).OrderBy(s.Column("name")).Limit(10)
+	assert.Equal(t, `SELECT "s"."name" FROM "public"."school" "s" WHERE "s"."city" = $1 AND "s"."enrollment" > 100 ORDER BY "s"."name" ASC LIMIT 10`,
+		stmtToSQL(NewContext(), sel))
+}
+
+func TestParse_Errors(t *testing.T) {
+	for sql, msg := range map[string]string{
+		`SELECT name FROM school, city`:            `ambiguous unqualified column "name" at offset 12`,
+		`SELECT "school"."name" FROM`:              `expected an identifier, found end of input at offset 27`,
+		`SELECT 'abc`:                              `unterminated string at offset 7`,
+		`SELECT $2, $1`:                            `arguments must be numbered in the order they appear at offset 0`,
+		`UPDATE t SET a = $2 WHERE id = $1`:        `arguments must be numbered in the order they appear at offset 0`,
+		`SELECT DISTINCT 1`:                        `SELECT DISTINCT is not supported at offset 7`,
+		`SELECT 1 UNION SELECT 2`:                  `unexpected "UNION" at offset 9`,
+		`SELECT rank() OVER () FROM "public"."s"`:  `window functions are not supported at offset 14`,
+	} {
+		stmt, err := Parse(sql)
+		assert.Nil(t, stmt, sql)
+		if assert.Error(t, err, sql) {
+			assert.Equal(t, msg, err.Error(), sql)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package pgqb
+
+import "strings"
+
+// Operator precedence, from the lowest to the highest (see "Operator Precedence" in the Postgres documentation).
+const (
+	// Operators of unknown precedence (i.e. made with CreateBinaryExpFactory, which may be keyword operators such
+	// as IS DISTINCT FROM): they and their compound operands are always surrounded with parentheses.
+	precUnknown = iota
+	precOr
+	precAnd
+	precNot
+	precIs
+	precComparison
+	precLike
+	// Any other built-in operator (i.e. ||, @>, ~).
+	precOther
+	precAdd
+	precMul
+	precExp
+	precCollate
+	precUnaryMinus
+	// Expressions that never need parentheses (i.e. columns, literals, function calls).
+	precAtom
+)
+
+var binaryOpPrecedence = map[string]int{
+	opIs:         precIs,
+	opIsNot:      precIs,
+	opGt:         precComparison,
+	opGte:        precComparison,
+	opEq:         precComparison,
+	opNe:         precComparison,
+	opLt:         precComparison,
+	opLte:        precComparison,
+	opLike:       precLike,
+	opNotLike:    precLike,
+	opSimilar:    precLike,
+	opNotSimilar: precLike,
+	opIn:         precLike,
+	opNotIn:      precLike,
+	opAdd:        precAdd,
+	opSub:        precAdd,
+	opMul:        precMul,
+	opDiv:        precMul,
+	opMod:        precMul,
+	opExp:        precExp,
+
+	opBitAnd:      precOther,
+	opBitOr:       precOther,
+	opBitXor:      precOther,
+	opLeftShift:   precOther,
+	opRightShift:  precOther,
+	opMatch:       precOther,
+	opInsMatch:    precOther,
+	opNotMatch:    precOther,
+	opNotInsMatch: precOther,
+	opContains:    precOther,
+	opContainedBy: precOther,
+	opUnion:       precOther,
+	opIntersect:   precOther,
+	// JSON operators.
+	"->":  precOther,
+	"->>": precOther,
+	"#>":  precOther,
+	"#>>": precOther,
+}
+
+// Characters operator names are made of (see "Operators" in the Postgres documentation).
+const operatorChars = "+-*/<>=~!@#%^&|`?"
+
+// Whether the string is an operator name (i.e. <, @>), as opposed to a keyword or arbitrary SQL.
+func isOperator(op string) bool {
+	if op == "" {
+		return false
+	}
+	for _, c := range op {
+		if !strings.ContainsRune(operatorChars, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Operators of these levels are not associative (i.e. a = b = c is an error).
+func isNonAssociative(prec int) bool {
+	return prec == precIs || prec == precComparison || prec == precLike
+}
+
+// Precedence of the outermost operator of the expression.
+func precedence(exp ColExp) int {
+	switch n := exp.(type) {
+	case *LogicalExpNode:
+		switch len(n.expList) {
+		case 0:
+			return precAtom
+		case 1:
+			return precedence(n.expList[0])
+		}
+		if n.op == opOr {
+			return precOr
+		}
+		return precAnd
+	case *BinaryExpNode:
+		if prec, in := binaryOpPrecedence[n.op]; in {
+			return prec
+		}
+		return precUnknown
+	case *UnaryExpNode:
+		switch n.op {
+		case opNot:
+			return precNot
+		case opNeg:
+			return precUnaryMinus
+		case opAbs, opFactorial:
+			return precOther
+		}
+		return precUnknown
+	case *CollateNode:
+		return precCollate
+	}
+	return precAtom
+}
+
+// Render an operand, surrounding it with parentheses when its operator binds less tightly than minPrec. In
+// ContextModeFullParentheses every operand with an operator is surrounded.
+func operandToSQL(exp ColExp, minPrec int, ctx *buildContext) {
+	prec := precedence(exp)
+	if prec < minPrec || (ctx.FullParentheses() && prec < precAtom) {
+		ctx.buf.WriteByte('(')
+		exp.toSQL(ctx)
+		ctx.buf.WriteByte(')')
+	} else {
+		exp.toSQL(ctx)
+	}
+}
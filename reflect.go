@@ -21,6 +21,10 @@ var columnsModel = struct {
 	DataType        *ColumnNode
 	IsNullable      *ColumnNode
 	OrdinalPosition *ColumnNode
+	CharMaxLength   *ColumnNode
+	NumericPrec     *ColumnNode
+	NumericScale    *ColumnNode
+	UdtName         *ColumnNode
 }{
 	Model:           columnsTable,
 	ColumnName:      Column(columnsTable, "column_name"),
@@ -29,6 +33,10 @@ var columnsModel = struct {
 	DataType:        Column(columnsTable, "data_type"),
 	IsNullable:      Column(columnsTable, "is_nullable"),
 	OrdinalPosition: Column(columnsTable, "ordinal_position"),
+	CharMaxLength:   Column(columnsTable, "character_maximum_length"),
+	NumericPrec:     Column(columnsTable, "numeric_precision"),
+	NumericScale:    Column(columnsTable, "numeric_scale"),
+	UdtName:         Column(columnsTable, "udt_name"),
 }
 
 type columns struct {
@@ -38,6 +46,12 @@ type columns struct {
 	DataType        string `db:"data_type"`
 	IsNullable      bool   `db:"is_nullable"`
 	OrdinalPosition int    `db:"ordinal_position"`
+	// Type modifiers; zero when not applicable or unspecified (i.e. an unbounded varchar).
+	CharMaxLength int `db:"character_maximum_length"`
+	NumericPrec   int `db:"numeric_precision"`
+	NumericScale  int `db:"numeric_scale"`
+	// Underlying type name; the element type prefixed with "_" for arrays (i.e. _int4).
+	UdtName string `db:"udt_name"`
 }
 
 func (c *columns) MemberName() string {
@@ -49,8 +63,13 @@ func (c *columns) MemberName() string {
 func GetAllTables(db *sql.DB, exclSchema ... interface{}) map[string][]*columns {
 	res := map[string][]*columns{}
 	cols := columnsModel
-	stmt := Select(cols.ColumnName, cols.TableName, cols.TableSchema, cols.DataType, cols.IsNullable).
-		Where(cols.TableSchema.NotIn(Tuple(exclSchema...)))
+	// is_nullable holds 'YES' / 'NO' rather than a boolean.
+	stmt := Select(cols.ColumnName, cols.TableName, cols.TableSchema, cols.DataType, cols.IsNullable.Eq("YES"),
+		cols.CharMaxLength, cols.NumericPrec, cols.NumericScale, cols.UdtName).
+		OrderBy(cols.TableSchema, cols.TableName, cols.OrdinalPosition)
+	if len(exclSchema) > 0 {
+		stmt.Where(cols.TableSchema.NotIn(Tuple(exclSchema...)))
+	}
 	query := NewContext().ToSQL(stmt)
 	rows, err := db.Query(query)
 	if err != nil {
@@ -58,7 +77,12 @@ func GetAllTables(db *sql.DB, exclSchema ... interface{}) map[string][]*columns
 	}
 	for rows.Next() {
 		col := columns{}
-		rows.Scan(&col.ColumnName, &col.TableName, &col.TableSchema, &col.DataType, &col.IsNullable)
+		var charMaxLength, numericPrec, numericScale sql.NullInt64
+		rows.Scan(&col.ColumnName, &col.TableName, &col.TableSchema, &col.DataType, &col.IsNullable,
+			&charMaxLength, &numericPrec, &numericScale, &col.UdtName)
+		col.CharMaxLength = int(charMaxLength.Int64)
+		col.NumericPrec = int(numericPrec.Int64)
+		col.NumericScale = int(numericScale.Int64)
 		// TODO: Do something with the column info
 		fullName := col.TableSchema + "." + col.TableName
 		if _, in := res[fullName]; !in {
@@ -0,0 +1,126 @@
+package pgqb
+
+import "sync"
+
+// Soft deletion: rows of tables with a soft-delete column are marked as deleted instead of being removed.
+
+// Schema.table -> soft-delete column (see TableNode.SoftDelete).
+var softDeleteColumns = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// Declare the column marking the deleted rows (i.e. "deleted_at", NULL for the other rows). SELECTs then exclude
+// the deleted rows of the table (see SelectStmt.WithDeleted), and DeleteFrom and MERGE ... THEN DELETE set the
+// column to now() instead of removing the rows (see DeleteStmt.HardDelete). The declaration applies to the table
+// by schema and name, so every node of the table (i.e. one built separately) is affected; declare it along with
+// the table of the model.
+func (n *TableNode) SoftDelete(column string) *TableNode {
+	softDeleteColumns.Lock()
+	defer softDeleteColumns.Unlock()
+	softDeleteColumns.m[tableKey(n)] = column
+	return n
+}
+
+// The soft-delete column of the table, or nil if it has none.
+func (n *TableNode) SoftDeleteColumn() *ColumnNode {
+	if name, in := softDeleteColumn(n); in {
+		return n.Column(name)
+	}
+	return nil
+}
+
+func softDeleteColumn(table *TableNode) (string, bool) {
+	softDeleteColumns.RLock()
+	defer softDeleteColumns.RUnlock()
+	name, in := softDeleteColumns.m[tableKey(table)]
+	return name, in
+}
+
+func hasSoftDeleteColumns() bool {
+	softDeleteColumns.RLock()
+	defer softDeleteColumns.RUnlock()
+	return len(softDeleteColumns.m) > 0
+}
+
+// The filters of a table referenced by a SELECT, including the soft-delete condition unless withDeleted.
+func (ctx *buildContext) selectFilter(withDeleted bool) tableFilterFunc {
+	return func(table *TableNode, src ColSource) []interface{} {
+		res := ctx.tableFilterPredicates(table, src)
+		if col, in := softDeleteColumn(table); in && !withDeleted {
+			res = append(res, src.Column(col).Is(Null))
+		}
+		return res
+	}
+}
+
+// Include the soft-deleted rows of the tables in FROM (but not in subqueries, which have their own).
+func (s *SelectStmt) WithDeleted() *SelectStmt {
+	s.withDeleted = true
+	return s
+}
+
+// Remove the rows even if the table has a soft-delete column.
+func (s *DeleteStmt) HardDelete() *DeleteStmt {
+	s.hardDelete = true
+	return s
+}
+
+// The UPDATE marking the rows as deleted, or nil if they are to be removed.
+func (s *DeleteStmt) softDeleteStmt(ctx *buildContext) *UpdateStmt {
+	col := s.table.SoftDeleteColumn()
+	if s.hardDelete || col == nil {
+		return nil
+	}
+	res := Update(s.table, Set{col: SQL("now()")})
+	using := s.usingClause
+	if ctx.AutoFrom() {
+		using = s.autoUsingClause()
+	}
+	if using != nil {
+		res.From(using.tbExpList...)
+	}
+	res.whereClause = deepcopyClause(s.whereClause).(*whereClause)
+	// Keep the time the rows were first deleted.
+	res.Where(col.Is(Null))
+	res.returningClause = deepcopyClause(s.returningClause).(*returningClause)
+	return res
+}
+
+// Remove the target rows of the DELETE actions even if the target has a soft-delete column.
+func (s *MergeStmt) HardDelete() *MergeStmt {
+	s.hardDelete = true
+	return s
+}
+
+// The WHEN clauses to render, with the DELETE actions replaced by UPDATEs marking the target rows as deleted.
+func (s *MergeStmt) softDeleteWhenClauses(whenClauses []*mergeWhenClause) []*mergeWhenClause {
+	var table *TableNode
+	var src ColSource
+	switch n := s.target.node().(type) {
+	case *TableNode:
+		table, src = n, n
+	case *TableAliasNode:
+		table, _ = n.table.(*TableNode)
+		src = n
+	}
+	if s.hardDelete || table == nil {
+		return whenClauses
+	}
+	name, in := softDeleteColumn(table)
+	if !in {
+		return whenClauses
+	}
+	col := src.Column(name)
+	res := make([]*mergeWhenClause, len(whenClauses))
+	for i, when := range whenClauses {
+		res[i] = when
+		if when.action.op == mergeDelete {
+			w := *when
+			// Keep the time the rows were first deleted.
+			w.action = MergeUpdate(Set{col: SQL("coalesce(?, now())", col)})
+			res[i] = &w
+		}
+	}
+	return res
+}
@@ -0,0 +1,78 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+type customerModel struct {
+	Model
+	Id        *ColumnNode
+	Name      *ColumnNode
+	DeletedAt *ColumnNode
+}
+
+func (m *customerModel) As(alias string) *customerModel {
+	return newCustomerModel(m.Model.As(alias))
+}
+
+func newCustomerModel(src Model) *customerModel {
+	return &customerModel{
+		Model:     src,
+		Id:        Column(src, "id"),
+		Name:      Column(src, "name"),
+		DeletedAt: Column(src, "deleted_at"),
+	}
+}
+
+var customerTable = Table("public", "customer").SoftDelete("deleted_at")
+
+func CustomerModel() *customerModel {
+	return newCustomerModel(customerTable)
+}
+
+func TestSoftDelete(t *testing.T) {
+	cust := CustomerModel()
+	rest := RestaurantModel()
+	ctx := NewContext()
+
+	stmt := Select(cust.Name).Where(cust.Id.Eq(1))
+	assert.Equal(t, `SELECT "customer"."name" FROM "public"."customer" WHERE "customer"."id" = 1 AND "customer"."deleted_at" IS NULL`,
+		stmtToSQL(ctx, stmt))
+	// Rendering does not change the statement.
+	assert.Equal(t, `SELECT "customer"."name" WHERE "customer"."id" = 1`, stmtToSQL(&Context{}, stmt))
+	assert.Equal(t, `SELECT "customer"."name" FROM "public"."customer" WHERE "customer"."id" = 1`,
+		stmtToSQL(ctx, stmt.WithDeleted()))
+	// Declared by schema and name, so any node of the table will do.
+	other := Table("public", "customer")
+	assert.Equal(t, `SELECT "customer"."name" FROM "public"."customer" WHERE "customer"."deleted_at" IS NULL`,
+		stmtToSQL(ctx, Select(other.Column("name"))))
+	assert.Equal(t, `"customer"."deleted_at"`, AstToSQL(other.SoftDeleteColumn()))
+	assert.Nil(t, restaurantTable.SoftDeleteColumn())
+
+	// Aliases, the nullable side of outer joins and subqueries.
+	owner := cust.As("owner")
+	stmt = Select(rest.Name, owner.Name).From(rest.LeftOuterJoin(owner, rest.OwnerId.Eq(owner.Id))).
+		Where(rest.Id.In(SubQuery(Select(rest.Id).From(rest).Where(rest.OwnerId.Eq(SubQuery(Select(cust.Id).From(cust)))))))
+	assert.Equal(t, `SELECT "Restaurant"."Name", "owner"."name" FROM "public"."Restaurant" LEFT OUTER JOIN "public"."customer" "owner" ON ("Restaurant"."OwnerId" = "owner"."id" AND "owner"."deleted_at" IS NULL) WHERE "Restaurant"."Id" IN (SELECT "Restaurant"."Id" FROM "public"."Restaurant" WHERE "Restaurant"."OwnerId" = (SELECT "customer"."id" FROM "public"."customer" WHERE "customer"."deleted_at" IS NULL ) )`,
+		stmtToSQL(ctx, stmt))
+
+	del := DeleteFrom(customerTable).Where(cust.Id.Eq(1)).Returning(cust.Name)
+	assert.Equal(t, `UPDATE "public"."customer" SET "deleted_at" = now() WHERE "customer"."id" = 1 AND "customer"."deleted_at" IS NULL RETURNING "customer"."name"`,
+		stmtToSQL(ctx, del))
+	assert.Equal(t, `DELETE FROM "public"."customer" WHERE "customer"."id" = 1 RETURNING "customer"."name"`,
+		stmtToSQL(ctx, del.Make().HardDelete()))
+	assert.Equal(t, `DELETE FROM "public"."Restaurant" WHERE "Restaurant"."Id" = 1`,
+		stmtToSQL(ctx, DeleteFrom(restaurantTable).Where(rest.Id.Eq(1))))
+
+	// MERGE deletes the target rows by marking them as well.
+	v := ValuesTable("v", "id").Values(1)
+	c := cust.As("c")
+	merge := MergeInto(c).Using(v, c.Id.Eq(v.Column("id"))).
+		WhenMatched(MergeDelete(), c.Name.Eq("")).
+		WhenNotMatchedBySource(MergeDelete())
+	assert.Equal(t, `MERGE INTO "public"."customer" "c" USING (VALUES (1)) "v"("id") ON "c"."id" = "v"."id" WHEN MATCHED AND "c"."name" = '' THEN UPDATE SET "deleted_at" = coalesce("c"."deleted_at", now()) WHEN NOT MATCHED BY SOURCE THEN UPDATE SET "deleted_at" = coalesce("c"."deleted_at", now())`,
+		stmtToSQL(ctx, merge))
+	assert.Equal(t, `MERGE INTO "public"."customer" "c" USING (VALUES (1)) "v"("id") ON "c"."id" = "v"."id" WHEN MATCHED AND "c"."name" = '' THEN DELETE WHEN NOT MATCHED BY SOURCE THEN DELETE`,
+		stmtToSQL(ctx, merge.Make().HardDelete()))
+}
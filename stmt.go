@@ -1,13 +1,14 @@
 package pgqb
 
 import (
-	"strconv"
 	"reflect"
 )
 
 type Stmt interface {
 	isStmt()
 	toSQL(ctx *buildContext)
+	// Deep-copy the statement, so that changes to the copy never affect the original.
+	Clone() Stmt
 }
 
 // Select statement.
@@ -18,8 +19,13 @@ type SelectStmt struct {
 	groupByClause *groupByClause
 	havingClause  *havingClause
 	orderByClause *orderByClause
-	limit         int
-	offset        int
+	// Nil when not set.
+	limit    ColExp
+	offset   ColExp
+	fetch    ColExp
+	withTies bool
+	// Include the soft-deleted rows (see WithDeleted).
+	withDeleted bool
 }
 
 func (SelectStmt) isStmt() {}
@@ -57,6 +63,34 @@ func (s *SelectStmt) Where(exps ... interface{}) *SelectStmt {
 	return s
 }
 
+// Same as Where, but only when cond is true.
+func (s *SelectStmt) WhereIf(cond bool, exps ... interface{}) *SelectStmt {
+	if !cond {
+		return s
+	}
+	return s.Where(exps...)
+}
+
+// Reusable modification of a select statement (i.e. a common filter).
+type Scope func(*SelectStmt) *SelectStmt
+
+// Apply the scopes in order; nil scopes are skipped.
+func (s *SelectStmt) Apply(scopes ... Scope) *SelectStmt {
+	for _, scope := range scopes {
+		if scope != nil {
+			s = scope(s)
+		}
+	}
+	return s
+}
+
+// Combine several scopes into one.
+func Scopes(scopes ... Scope) Scope {
+	return func(s *SelectStmt) *SelectStmt {
+		return s.Apply(scopes...)
+	}
+}
+
 func (s *SelectStmt) GroupBy(exps ... interface{}) *SelectStmt {
 	if len(exps) == 0 {
 		return s
@@ -90,46 +124,110 @@ func (s *SelectStmt) OrderBy(exps ... interface{}) *SelectStmt {
 	return s
 }
 
-func (s *SelectStmt) Limit(n int) *SelectStmt {
-	s.limit = n
+// Accept an int or an expression (i.e. an argument); nil removes the limit. Replaces FETCH FIRST, if any.
+func (s *SelectStmt) Limit(n interface{}) *SelectStmt {
+	s.limit = getOptionalExp(n)
+	s.fetch, s.withTies = nil, false
+	return s
+}
+
+// LIMIT ALL, which is the same as no limit.
+func (s *SelectStmt) LimitAll() *SelectStmt {
+	return s.Limit(limitAll)
+}
+
+// Accept an int or an expression (i.e. an argument); nil removes the offset.
+func (s *SelectStmt) Offset(n interface{}) *SelectStmt {
+	s.offset = getOptionalExp(n)
 	return s
 }
 
-func (s *SelectStmt) Offset(n int) *SelectStmt {
-	s.offset = n
+// FETCH FIRST n ROWS ONLY; nil removes it. Replaces LIMIT, if any.
+func (s *SelectStmt) FetchFirst(n interface{}) *SelectStmt {
+	s.fetch = getOptionalExp(n)
+	s.limit, s.withTies = nil, false
 	return s
 }
 
+// FETCH FIRST n ROWS WITH TIES, which also returns the rows that tie with the last one according to ORDER BY.
+func (s *SelectStmt) FetchFirstWithTies(n interface{}) *SelectStmt {
+	s.FetchFirst(n)
+	s.withTies = s.fetch != nil
+	return s
+}
+
+func (s *SelectStmt) limitToSQL(ctx *buildContext) {
+	if s.limit != nil {
+		ctx.buf.WriteString("LIMIT ")
+		s.limit.toSQL(ctx)
+		ctx.lineBreak()
+	}
+	if s.offset != nil {
+		ctx.buf.WriteString("OFFSET ")
+		s.offset.toSQL(ctx)
+		ctx.lineBreak()
+	}
+	if s.fetch != nil {
+		if s.withTies && s.orderByClause == nil {
+			panic("WITH TIES requires ORDER BY")
+		}
+		ctx.buf.WriteString("FETCH FIRST ")
+		switch s.fetch.(type) {
+		case *LiteralNode, *ArgumentNode, *GroupExpNode:
+			s.fetch.toSQL(ctx)
+		default:
+			// Only simple values are allowed without parentheses.
+			ctx.buf.WriteByte('(')
+			s.fetch.toSQL(ctx)
+			ctx.buf.WriteByte(')')
+		}
+		if s.withTies {
+			ctx.buf.WriteString(" ROWS WITH TIES")
+		} else {
+			ctx.buf.WriteString(" ROWS ONLY")
+		}
+		ctx.lineBreak()
+	}
+}
+
 func (s *SelectStmt) toSQL(ctx *buildContext) {
+	// Rendering must not change the statement, so missing column sources go to a copy of the FROM clause.
+	from := s.fromClause
 	if ctx.AutoFrom() {
-		usedColSrc := collectColSourcesFromClauses(
-			s.selectClause, s.whereClause, s.groupByClause, s.havingClause,
-			s.orderByClause)
-		if s.fromClause == nil {
-			s.fromClause = &fromClause{}
-		}
-		s.fromClause.fillMissingColSrc(usedColSrc)
+		from = s.autoFromClause()
+	}
+	where := s.whereClause
+	if from != nil {
+		res := &fromClause{}
+		res.tbExpList, where = ctx.applyTableFilters(nil, from.tbExpList, where, ctx.selectFilter(s.withDeleted))
+		from = res
 	}
 	origState := ctx.state
 	ctx.state = buildContextStateColumnDeclaration
 	clauseToSQL(s.selectClause, ctx)
 	ctx.state = origState
-	clauseToSQL(s.fromClause, ctx)
-	clauseToSQL(s.whereClause, ctx)
+	clauseToSQL(from, ctx)
+	clauseToSQL(where, ctx)
 	clauseToSQL(s.groupByClause, ctx)
 	clauseToSQL(s.havingClause, ctx)
 	clauseToSQL(s.orderByClause, ctx)
-	if s.limit > 0 {
-		ctx.buf.WriteString("LIMIT " + strconv.FormatInt(int64(s.limit), 10) + " ")
-	}
-	if s.offset > 0 {
-		ctx.buf.WriteString("OFFSET " + strconv.FormatInt(int64(s.offset), 10) + " ")
-	}
+	s.limitToSQL(ctx)
+}
+
+// The FROM clause with the missing column sources added (see ContextModeAutoFrom).
+func (s *SelectStmt) autoFromClause() *fromClause {
+	usedColSrc := collectColSourcesFromClauses(
+		s.selectClause, s.whereClause, s.groupByClause, s.havingClause,
+		s.orderByClause)
+	return s.fromClause.withMissingColSrc(usedColSrc)
 }
 
 // Create a snapshot (deep-copy) of the Stmt object.
 func (s *SelectStmt) Make() *SelectStmt {
-	res := &SelectStmt{limit: s.limit, offset: s.offset}
+	res := &SelectStmt{withTies: s.withTies, withDeleted: s.withDeleted}
+	res.limit = deepcopyColExp(s.limit)
+	res.offset = deepcopyColExp(s.offset)
+	res.fetch = deepcopyColExp(s.fetch)
 	res.selectClause = deepcopyClause(s.selectClause).(*selectClause)
 	res.whereClause = deepcopyClause(s.whereClause).(*whereClause)
 	res.fromClause = deepcopyClause(s.fromClause).(*fromClause)
@@ -139,6 +237,10 @@ func (s *SelectStmt) Make() *SelectStmt {
 	return res
 }
 
+func (s *SelectStmt) Clone() Stmt {
+	return s.Make()
+}
+
 func Select(exps ... interface{}) *SelectStmt {
 	res := &SelectStmt{}
 	res.Select(exps...)
@@ -167,10 +269,8 @@ func (s *InsertStmt) into(table *TableNode, cols ... *ColumnNode) {
 	s.insertClause = &insertClause{table: table, columns: cols}
 }
 
+// Without expressions, insert a single row of default values (i.e. INSERT INTO t DEFAULT VALUES).
 func (s *InsertStmt) DefaultValues(exps ... interface{}) *InsertStmt {
-	if len(exps) == 0 {
-		return s
-	}
 	if s.defaultValuesClause == nil {
 		s.defaultValuesClause = &defaultValuesClause{}
 	}
@@ -223,9 +323,10 @@ func (s *InsertStmt) Returning(exps ... interface{}) *InsertStmt {
 	return s
 }
 
-func (s *InsertStmt) On(target ConflictTarget, action *conflictClause) *InsertStmt {
+// Pass a nil target to catch conflicts on any constraint (only valid with DO NOTHING).
+func (s *InsertStmt) On(target *ConflictTarget, action *conflictClause) *InsertStmt {
 	s.conflictClause = action
-	action.cols = target
+	action.target = target
 	return s
 }
 
@@ -246,6 +347,10 @@ func (s *InsertStmt) Make() *InsertStmt {
 	return res
 }
 
+func (s *InsertStmt) Clone() Stmt {
+	return s.Make()
+}
+
 func (s *InsertStmt) isStmt() {}
 
 func DoNothing() *conflictClause {
@@ -253,7 +358,7 @@ func DoNothing() *conflictClause {
 }
 
 func DoUpdate(setter Set) *conflictClause {
-	return &conflictClause{setClause: &setClause{setExpMap: setter.mapNamesToColExps()}}
+	return &conflictClause{setClause: newSetClause(setter)}
 }
 
 func InsertInto(table *TableNode, cols ... *ColumnNode) *InsertStmt {
@@ -274,27 +379,43 @@ type UpdateStmt struct {
 func (s *UpdateStmt) isStmt() {}
 
 func (s *UpdateStmt) toSQL(ctx *buildContext) {
+	if s.setClause == nil || len(s.setClause.assignments) == 0 {
+		panic("UPDATE requires at least one assignment")
+	}
+	from := s.fromClause
 	if ctx.AutoFrom() {
-		usedColSrc := collectColSourcesFromClauses(s.setClause, s.whereClause)
-		if _, in := usedColSrc[s.table.name()]; in {
-			delete(usedColSrc, s.table.name())
-		}
-		if len(usedColSrc) > 0 {
-			if s.fromClause == nil {
-				s.fromClause = &fromClause{}
-			}
-			s.fromClause.fillMissingColSrc(usedColSrc)
-		}
+		from = s.autoFromClause()
+	}
+	var exps []TableExp
+	if from != nil {
+		exps = from.tbExpList
+	}
+	exps, where := ctx.applyTableFilters(s.table, exps, s.whereClause, ctx.tableFilterPredicates)
+	if from != nil {
+		from = &fromClause{}
+		from.tbExpList = exps
 	}
 	ctx.buf.WriteString("UPDATE ")
 	s.table.toSQL(ctx)
-	ctx.buf.WriteByte(' ')
+	ctx.lineBreak()
 	clauseToSQL(s.setClause, ctx)
-	clauseToSQL(s.fromClause, ctx)
-	clauseToSQL(s.whereClause, ctx)
+	clauseToSQL(from, ctx)
+	clauseToSQL(where, ctx)
 	clauseToSQL(s.returningClause, ctx)
 }
 
+// The FROM clause with the missing column sources other than the updated table added (see ContextModeAutoFrom).
+func (s *UpdateStmt) autoFromClause() *fromClause {
+	usedColSrc := collectColSourcesFromClauses(s.setClause, s.whereClause)
+	if _, in := usedColSrc[s.table.name()]; in {
+		delete(usedColSrc, s.table.name())
+	}
+	if len(usedColSrc) == 0 {
+		return s.fromClause
+	}
+	return s.fromClause.withMissingColSrc(usedColSrc)
+}
+
 func (s *UpdateStmt) From(exps ... TableExp) *UpdateStmt {
 	if len(exps) == 0 {
 		return s
@@ -317,6 +438,14 @@ func (s *UpdateStmt) Where(exps ... interface{}) *UpdateStmt {
 	return s
 }
 
+// Same as Where, but only when cond is true.
+func (s *UpdateStmt) WhereIf(cond bool, exps ... interface{}) *UpdateStmt {
+	if !cond {
+		return s
+	}
+	return s.Where(exps...)
+}
+
 func (s *UpdateStmt) Returning(exps ... interface{}) *UpdateStmt {
 	if len(exps) == 0 {
 		return s
@@ -328,44 +457,97 @@ func (s *UpdateStmt) Returning(exps ... interface{}) *UpdateStmt {
 	return s
 }
 
+// Add the assignments, replacing the existing ones to the same targets.
+func (s *UpdateStmt) Set(set Set) *UpdateStmt {
+	if s.setClause == nil {
+		s.setClause = &setClause{}
+	}
+	for _, assignment := range newSetClause(set).assignments {
+		s.setClause.set(assignment.target, assignment.value)
+	}
+	return s
+}
+
+// Remove the assignments to the targets.
+func (s *UpdateStmt) Unset(targets ... SetTarget) *UpdateStmt {
+	if s.setClause == nil {
+		return s
+	}
+	for _, target := range targets {
+		s.setClause.unset(target)
+	}
+	return s
+}
+
+// Create a snapshot (deep-copy) of the Stmt object.
+func (s *UpdateStmt) Make() *UpdateStmt {
+	res := &UpdateStmt{table: s.table}
+	res.setClause = deepcopyClause(s.setClause).(*setClause)
+	res.fromClause = deepcopyClause(s.fromClause).(*fromClause)
+	res.whereClause = deepcopyClause(s.whereClause).(*whereClause)
+	res.returningClause = deepcopyClause(s.returningClause).(*returningClause)
+	return res
+}
+
+func (s *UpdateStmt) Clone() Stmt {
+	return s.Make()
+}
+
 func Update(table *TableNode, set Set) *UpdateStmt {
-	stmt := &UpdateStmt{table: table, setClause: &setClause{setExpMap: set.mapNamesToColExps()}}
+	stmt := &UpdateStmt{table: table, setClause: newSetClause(set)}
 	return stmt
 }
 
-// TODO: Allow developers to change update values
-
 // DeleteFrom statement.
 type DeleteStmt struct {
 	table           *TableNode
 	usingClause     *usingClause
 	whereClause     *whereClause
 	returningClause *returningClause
+	// Remove the rows even if the table has a soft-delete column (see HardDelete).
+	hardDelete bool
 }
 
 func (s *DeleteStmt) isStmt() {}
 
 func (s *DeleteStmt) toSQL(ctx *buildContext) {
+	if res := s.softDeleteStmt(ctx); res != nil {
+		res.toSQL(ctx)
+		return
+	}
+	using := s.usingClause
 	if ctx.AutoFrom() {
-		usedColSrc := collectColSourcesFromClauses(s.returningClause, s.whereClause)
-		if _, in := usedColSrc[s.table.name()]; in {
-			delete(usedColSrc, s.table.name())
-		}
-		if len(usedColSrc) > 0 {
-			if s.usingClause == nil {
-				s.usingClause = &usingClause{}
-			}
-			s.usingClause.fillMissingColSrc(usedColSrc)
-		}
+		using = s.autoUsingClause()
+	}
+	var exps []TableExp
+	if using != nil {
+		exps = using.tbExpList
+	}
+	exps, where := ctx.applyTableFilters(s.table, exps, s.whereClause, ctx.tableFilterPredicates)
+	if using != nil {
+		using = &usingClause{}
+		using.tbExpList = exps
 	}
 	ctx.buf.WriteString("DELETE FROM ")
 	s.table.toSQL(ctx)
-	ctx.buf.WriteByte(' ')
-	clauseToSQL(s.usingClause, ctx)
-	clauseToSQL(s.whereClause, ctx)
+	ctx.lineBreak()
+	clauseToSQL(using, ctx)
+	clauseToSQL(where, ctx)
 	clauseToSQL(s.returningClause, ctx)
 }
 
+// The USING clause with the missing column sources other than the deleted table added (see ContextModeAutoFrom).
+func (s *DeleteStmt) autoUsingClause() *usingClause {
+	usedColSrc := collectColSourcesFromClauses(s.returningClause, s.whereClause)
+	if _, in := usedColSrc[s.table.name()]; in {
+		delete(usedColSrc, s.table.name())
+	}
+	if len(usedColSrc) == 0 {
+		return s.usingClause
+	}
+	return s.usingClause.withMissingColSrc(usedColSrc)
+}
+
 func (s *DeleteStmt) Using(exps ... TableExp) *DeleteStmt {
 	if len(exps) == 0 {
 		return s
@@ -388,6 +570,14 @@ func (s *DeleteStmt) Where(exps ... interface{}) *DeleteStmt {
 	return s
 }
 
+// Same as Where, but only when cond is true.
+func (s *DeleteStmt) WhereIf(cond bool, exps ... interface{}) *DeleteStmt {
+	if !cond {
+		return s
+	}
+	return s.Where(exps...)
+}
+
 func (s *DeleteStmt) Returning(exps ... interface{}) *DeleteStmt {
 	if len(exps) == 0 {
 		return s
@@ -399,10 +589,146 @@ func (s *DeleteStmt) Returning(exps ... interface{}) *DeleteStmt {
 	return s
 }
 
+// Create a snapshot (deep-copy) of the Stmt object.
+func (s *DeleteStmt) Make() *DeleteStmt {
+	res := &DeleteStmt{table: s.table, hardDelete: s.hardDelete}
+	res.usingClause = deepcopyClause(s.usingClause).(*usingClause)
+	res.whereClause = deepcopyClause(s.whereClause).(*whereClause)
+	res.returningClause = deepcopyClause(s.returningClause).(*returningClause)
+	return res
+}
+
+func (s *DeleteStmt) Clone() Stmt {
+	return s.Make()
+}
+
 func DeleteFrom(table *TableNode) *DeleteStmt {
 	return &DeleteStmt{table: table}
 }
 
+// Merge statement (Postgres 15+).
+type MergeStmt struct {
+	target          TableExp
+	usingClause     *usingClause
+	onExp           ColExp
+	whenClauses     []*mergeWhenClause
+	returningClause *returningClause
+	hardDelete      bool
+}
+
+func (s *MergeStmt) isStmt() {}
+
+func (s *MergeStmt) toSQL(ctx *buildContext) {
+	if s.usingClause == nil || len(s.whenClauses) == 0 {
+		panic("MERGE requires a source and at least one WHEN clause")
+	}
+	exps, onExp, whenClauses := ctx.applyMergeFilters(s)
+	whenClauses = s.softDeleteWhenClauses(whenClauses)
+	using := &usingClause{}
+	using.tbExpList = exps
+	ctx.buf.WriteString("MERGE INTO ")
+	s.target.toSQL(ctx)
+	ctx.lineBreak()
+	clauseToSQL(using, ctx)
+	ctx.buf.WriteString("ON ")
+	onExp.toSQL(ctx)
+	ctx.lineBreak()
+	for _, when := range whenClauses {
+		when.toSQL(ctx)
+	}
+	clauseToSQL(s.returningClause, ctx)
+}
+
+// Should only be called once.
+func (s *MergeStmt) Using(src TableExp, onExp ColExp) *MergeStmt {
+	s.usingClause = &usingClause{}
+	s.usingClause.addTableExp(src)
+	s.onExp = onExp
+	return s
+}
+
+func (s *MergeStmt) when(match string, action *mergeAction, conds []interface{}) *MergeStmt {
+	whenClause := &mergeWhenClause{match: match, action: action}
+	if len(conds) > 0 {
+		whenClause.condition = And(conds...)
+	}
+	s.whenClauses = append(s.whenClauses, whenClause)
+	return s
+}
+
+// Action for the target rows that have a matching source row (UPDATE, DELETE or DO NOTHING).
+func (s *MergeStmt) WhenMatched(action *mergeAction, conds ... interface{}) *MergeStmt {
+	if action.op == mergeInsert {
+		panic("invalid operation")
+	}
+	return s.when(whenMatched, action, conds)
+}
+
+// Action for the source rows that have no matching target row (INSERT or DO NOTHING).
+func (s *MergeStmt) WhenNotMatched(action *mergeAction, conds ... interface{}) *MergeStmt {
+	if action.op != mergeInsert && action.op != mergeDoNothing {
+		panic("invalid operation")
+	}
+	return s.when(whenNotMatched, action, conds)
+}
+
+// Action for the target rows that have no matching source row (Postgres 17+).
+func (s *MergeStmt) WhenNotMatchedBySource(action *mergeAction, conds ... interface{}) *MergeStmt {
+	if action.op == mergeInsert {
+		panic("invalid operation")
+	}
+	return s.when(whenNotMatchedBySource, action, conds)
+}
+
+// Postgres 17+.
+func (s *MergeStmt) Returning(exps ... interface{}) *MergeStmt {
+	if len(exps) == 0 {
+		return s
+	}
+	if s.returningClause == nil {
+		s.returningClause = &returningClause{}
+	}
+	s.returningClause.addColExp(exps...)
+	return s
+}
+
+// Create a snapshot (deep-copy) of the Stmt object.
+func (s *MergeStmt) Make() *MergeStmt {
+	res := &MergeStmt{target: deepcopyTableExp(s.target), onExp: deepcopyColExp(s.onExp), hardDelete: s.hardDelete}
+	res.usingClause = deepcopyClause(s.usingClause).(*usingClause)
+	res.returningClause = deepcopyClause(s.returningClause).(*returningClause)
+	res.whenClauses = make([]*mergeWhenClause, len(s.whenClauses))
+	for i, when := range s.whenClauses {
+		res.whenClauses[i] = when.deepcopy().(*mergeWhenClause)
+	}
+	return res
+}
+
+func (s *MergeStmt) Clone() Stmt {
+	return s.Make()
+}
+
+func MergeInto(target TableExp) *MergeStmt {
+	return &MergeStmt{target: target}
+}
+
+func MergeUpdate(set Set) *mergeAction {
+	return &mergeAction{op: mergeUpdate, setClause: newSetClause(set)}
+}
+
+func MergeDelete() *mergeAction {
+	return &mergeAction{op: mergeDelete}
+}
+
+func MergeDoNothing() *mergeAction {
+	return &mergeAction{op: mergeDoNothing}
+}
+
+// Call Values on the result to set the row; it inserts DEFAULT VALUES otherwise.
+func MergeInsert(cols ... *ColumnNode) *mergeAction {
+	return &mergeAction{op: mergeInsert, columns: cols, defaultValues: true}
+}
+
 // Helper for deep-copying a clause.
 func deepcopyClause(src clause) interface{} {
 	if !isNull(src) {
@@ -411,20 +737,33 @@ func deepcopyClause(src clause) interface{} {
 	return reflect.New(reflect.TypeOf(src)).Elem().Interface()
 }
 
-type Set map[*ColumnNode]interface{}
+// Target of an assignment: a column, a column list (i.e. Columns(a, b)) or an element of a column (i.e.
+// Subscript(col, 1)).
+type SetTarget interface {
+	astNode
+	isSetTarget()
+}
+
+// Target -> value. Values for column lists are row constructors (i.e. Row(x, y)) or subqueries (i.e.
+// SubQuery(stmt)).
+type Set map[SetTarget]interface{}
 
-func (s Set) mapNamesToColExps() map[string]ColExp {
-	var res = make(map[string]ColExp, len(s))
-	for col, exp := range s {
-		res[col.name] = getExp(exp)
+// Conflict target made of index columns.
+func Conflict(cols ... *ColumnNode) *ConflictTarget {
+	exps := make([]ColExp, len(cols))
+	for i, col := range cols {
+		exps[i] = col
 	}
-	return res
+	return &ConflictTarget{exps: exps}
 }
 
-type ConflictTarget []*ColumnNode
+// Conflict target made of index columns or expressions (i.e. lower(email)).
+func ConflictExp(exps ... interface{}) *ConflictTarget {
+	return &ConflictTarget{exps: getExpList(exps)}
+}
 
-func Conflict(cols ... *ColumnNode) ConflictTarget {
-	return ConflictTarget(cols)
+func OnConstraint(name string) *ConflictTarget {
+	return &ConflictTarget{constraint: name}
 }
 
 // TODO: Refactor the code a bit.
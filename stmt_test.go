@@ -50,6 +50,94 @@ func TestSelectStmt(t *testing.T) {
 	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" DESC, "school"."name" ASC LIMIT 30`, sql)
 }
 
+func TestSelectStmt_Limit(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c3 := Column(t1, "enrollment")
+
+	ctx := NewContext()
+	stmt := Select(c1).Limit(0).Offset(0)
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" LIMIT 0 OFFSET 0`, stmtToSQL(ctx, stmt))
+	stmt.Limit(Arg("limit")).Offset(Arg("offset"))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" LIMIT $1 OFFSET $2`, stmtToSQL(ctx, stmt))
+	stmt.LimitAll().Offset(nil)
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" LIMIT ALL`, stmtToSQL(ctx, stmt))
+	stmt.Limit(nil)
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school"`, stmtToSQL(ctx, stmt))
+
+	stmt.OrderBy(Desc(c3)).Offset(20).FetchFirstWithTies(10)
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" DESC OFFSET 20 FETCH FIRST 10 ROWS WITH TIES`,
+		stmtToSQL(ctx, stmt))
+	stmt.FetchFirst(Arg("n").Add(1))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" DESC OFFSET 20 FETCH FIRST ($1 + 1) ROWS ONLY`,
+		stmtToSQL(ctx, stmt))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" ORDER BY "school"."enrollment" DESC LIMIT 5 OFFSET 20`,
+		stmtToSQL(ctx, stmt.Clone().(*SelectStmt).Limit(5)))
+	assert.Panics(t, func() {
+		stmtToSQL(ctx, Select(c1).FetchFirstWithTies(1))
+	})
+}
+
+func TestSelectStmt_Lateral(t *testing.T) {
+	t1 := Table("public", "city")
+	t2 := Table("public", "school")
+	sub := SubQueryTableExp(Select(Column(t2, "name")).From(t2).
+		Where(Column(t2, "city").Eq(Column(t1, "name"))).OrderBy(Desc(Column(t2, "enrollment"))).Limit(3), "top")
+
+	ctx := NewContext()
+	sql := stmtToSQL(ctx, Select(Column(t1, "name"), sub.Column("name")).
+		From(t1.LeftOuterJoin(Lateral(sub), Literal(true))))
+	assert.Equal(t, `SELECT "city"."name", "top"."name" FROM "public"."city" LEFT OUTER JOIN LATERAL (SELECT "school"."name" FROM "public"."school" WHERE "school"."city" = "city"."name" ORDER BY "school"."enrollment" DESC LIMIT 3 ) "top" ON (true)`, sql)
+}
+
+func TestSelectStmt_TableFunc(t *testing.T) {
+	t1 := Table("public", "school")
+	ids := TableFunc(FuncCall("unnest", Arg("ids")), "ids").WithOrdinality().ColumnAliases("id", "ord")
+
+	ctx := NewContext()
+	sql := stmtToSQL(ctx, Select(Star(t1)).Where(Column(t1, "id").Eq(ids.Column("id"))).OrderBy(ids.Column("ord")))
+	expSQLTmpl := `SELECT "school".* FROM %s, %s WHERE "school"."id" = "ids"."id" ORDER BY "ids"."ord" ASC`
+	t1SQL := `"public"."school"`
+	idsSQL := `unnest($1) WITH ORDINALITY "ids"("id", "ord")`
+	assert.True(t, sql == fmt.Sprintf(expSQLTmpl, t1SQL, idsSQL) || sql == fmt.Sprintf(expSQLTmpl, idsSQL, t1SQL))
+}
+
+func TestSelectStmt_TableFuncReference(t *testing.T) {
+	t1 := Table("public", "school")
+	tags := TableFunc(FuncCall("unnest", Column(t1, "tags")), "tag")
+
+	// The table referenced by the function argument is pulled in before the function.
+	ctx := NewContext()
+	assert.Equal(t, `SELECT "school"."name", "tag"."tag" FROM "public"."school", unnest("school"."tags") "tag"`,
+		stmtToSQL(ctx, Select(Column(t1, "name"), tags.Column("tag"))))
+	assert.Equal(t, `SELECT "tag"."tag" FROM "public"."school", unnest("school"."tags") "tag"`,
+		stmtToSQL(ctx, Select(tags.Column("tag"))))
+
+	// Correlated subquery.
+	sub := Select(tags.Column("tag")).From(tags).Where(tags.Column("tag").Eq("public"))
+	assert.Equal(t, `SELECT "school"."id" FROM "public"."school" WHERE EXISTS (SELECT "tag"."tag" FROM unnest("school"."tags") "tag" WHERE "tag"."tag" = 'public' )`,
+		stmtToSQL(ctx, Select(Column(t1, "id")).Where(Exists(sub))))
+}
+
+func TestSelectStmt_ValuesTableReference(t *testing.T) {
+	t1 := Table("public", "school")
+	v := ValuesTable("v", "id", "name").Values(Column(t1, "id"), Column(t1, "name"))
+	ctx := NewContext()
+	assert.Equal(t, `SELECT "v"."name" FROM "public"."school", LATERAL (VALUES ("school"."id", "school"."name")) "v"("id", "name")`,
+		stmtToSQL(ctx, Select(v.Column("name")).From(Lateral(v))))
+}
+
+func TestSelectStmt_GroupingSets(t *testing.T) {
+	t1 := Table("public", "sales")
+	region := Column(t1, "region")
+	city := Column(t1, "city")
+	amount := Column(t1, "amount")
+
+	ctx := NewContext()
+	sql := stmtToSQL(ctx, Select(region, city, Grouping(region, city), Sum(amount)).GroupBy(amount.Gt(0), Rollup(region, city)))
+	assert.Equal(t, `SELECT "sales"."region", "sales"."city", GROUPING("sales"."region", "sales"."city"), sum("sales"."amount") FROM "public"."sales" GROUP BY "sales"."amount" > 0, ROLLUP ("sales"."region", "sales"."city")`, sql)
+}
+
 func TestSelectStmt_Make(t *testing.T) {
 	ctx := NewContext()
 	sel1 := Select(1, 2, 3)
@@ -59,6 +147,122 @@ func TestSelectStmt_Make(t *testing.T) {
 	assert.Equal(t, `SELECT 1, 2, 3, 4, 5, 6`, stmtToSQL(ctx, sel2))
 }
 
+func TestSelectStmt_Conditional(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+
+	ctx := NewContext()
+	var city *string
+	stmt := Select(c1).WhereIf(city != nil, c2.Eq(Arg("city"))).Where(And(), nil)
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school"`, stmtToSQL(ctx, stmt))
+	stmt.WhereIf(true, c3.Gt(100))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 100`, stmtToSQL(ctx, stmt))
+
+	large := func(s *SelectStmt) *SelectStmt { return s.Where(c3.Gt(1000)) }
+	ordered := func(s *SelectStmt) *SelectStmt { return s.OrderBy(c1) }
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 1000 ORDER BY "school"."name" ASC`,
+		stmtToSQL(ctx, Select(c1).Apply(nil, Scopes(large, ordered))))
+	base := ImmutableSelect(c1)
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."enrollment" > 1000`, stmtToSQL(ctx, base.Apply(large)))
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school"`, stmtToSQL(ctx, base.WhereIf(false, c3.Gt(0))))
+	assert.Equal(t, `DELETE FROM "public"."school"`, stmtToSQL(ctx, DeleteFrom(t1).WhereIf(false, c3.Gt(0))))
+}
+
+func TestContext_Pretty(t *testing.T) {
+	t1 := Table("public", "school")
+	t2 := Table("public", "city")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+	sub := Select(Column(t2, "name")).From(t2).Where(Column(t2, "state").Eq("WI"))
+	top := SubQueryTableExp(Select(Column(t2, "id")).From(t2).Limit(1), "top")
+	stmt := Select(c1, c2, c3, Column(t1, "address"), Column(t1, "principal"), Column(t1, "phone")).
+		From(t1.InnerJoin(t2, c2.Eq(Column(t2, "name"))).LeftOuterJoin(Lateral(top), Literal(true))).
+		Where(c2.In(SubQuery(sub)), c3.Gt(Arg("min"))).OrderBy(Desc(c3)).Limit(10)
+
+	ctx := NewContextWithMode(ContextModeAutoFrom | ContextModePretty)
+	assert.Equal(t, `SELECT
+  "school"."name",
+  "school"."city",
+  "school"."enrollment",
+  "school"."address",
+  "school"."principal",
+  "school"."phone"
+FROM "public"."school"
+  INNER JOIN "public"."city" ON ("school"."city" = "city"."name")
+  LEFT OUTER JOIN LATERAL (
+    SELECT "city"."id"
+    FROM "public"."city"
+    LIMIT 1
+  ) "top" ON (true)
+WHERE "school"."city" IN (
+  SELECT "city"."name"
+  FROM "public"."city"
+  WHERE "city"."state" = 'WI'
+) AND "school"."enrollment" > $1
+ORDER BY "school"."enrollment" DESC
+LIMIT 10`, ctx.ToSQL(stmt))
+	assert.Equal(t, "SELECT \"school\".\"name\"\nFROM \"public\".\"school\"", ctx.ToSQL(Select(c1)))
+	assert.Equal(t, `INSERT INTO "public"."school" ("name")
+VALUES ('a')
+ON CONFLICT ("name") DO NOTHING
+RETURNING "school"."name"`, ctx.ToSQL(InsertInto(t1, c1).Values("a").On(Conflict(c1), DoNothing()).Returning(c1)))
+
+	// Compact mode is unaffected.
+	assert.Equal(t, `SELECT "school"."name", "school"."city", "school"."enrollment", "school"."address", "school"."principal", "school"."phone" FROM "public"."school" INNER JOIN "public"."city" ON ("school"."city" = "city"."name") LEFT OUTER JOIN LATERAL (SELECT "city"."id" FROM "public"."city" LIMIT 1 ) "top" ON (true) WHERE "school"."city" IN (SELECT "city"."name" FROM "public"."city" WHERE "city"."state" = 'WI' ) AND "school"."enrollment" > $1 ORDER BY "school"."enrollment" DESC LIMIT 10 `,
+		NewContext().ToSQL(stmt))
+}
+
+func TestStmt_Clone(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	t2 := Table("public", "city")
+
+	ctx := NewContext()
+	sub := Select(Column(t2, "name")).From(t2)
+	fn := FuncCall("count", c1)
+	sel := Select(fn).Where(c2.In(SubQuery(sub))).From(t1)
+	sel2 := sel.Clone().(*SelectStmt)
+	sub.Where(Column(t2, "state").Eq("WA"))
+	fn.Distinct()
+	sel.Where(c1.Ne("Abc"))
+	assert.Equal(t, `SELECT count("school"."name") FROM "public"."school" WHERE "school"."city" IN (SELECT "city"."name" FROM "public"."city" )`, stmtToSQL(ctx, sel2))
+
+	ins := InsertInto(t1, c1).Values(Row(1, 2)).On(Conflict(c1), DoUpdate(Set{c2: "Seattle"}))
+	ins2 := ins.Clone().(*InsertStmt)
+	ins.getValueClause().valuesList[0][0].(*RowNode).expList[0] = Literal(3)
+	ins.conflictClause.target.Where(c2.Is(Null))
+	assert.Equal(t, `INSERT INTO "public"."school" ("name") VALUES ((1, 2)) ON CONFLICT ("name") DO UPDATE SET "city" = 'Seattle'`, stmtToSQL(ctx, ins2))
+
+	del := DeleteFrom(t1).Where(c1.Eq("Abc"))
+	del2 := del.Clone().(*DeleteStmt)
+	del.Where(c2.Eq("Madison")).Returning(c1)
+	assert.Equal(t, `DELETE FROM "public"."school" WHERE "school"."name" = 'Abc'`, stmtToSQL(ctx, del2))
+
+	upd := Update(t1, Set{c2: "Madison"}).Where(c1.Eq("Abc"))
+	upd2 := upd.Clone()
+	upd.Set(Set{c1: "Xyz"})
+	assert.Equal(t, `UPDATE "public"."school" SET "city" = 'Madison' WHERE "school"."name" = 'Abc'`, stmtToSQL(ctx, upd2))
+
+	create := CreateTable(t1).Columns(ColumnDef(c1, "text"))
+	create2 := create.Clone()
+	create.columns[0].NotNull()
+	assert.Equal(t, `CREATE TABLE "public"."school" ("name" text)`, stmtToSQL(ctx, create2))
+
+	def := FuncCall("coalesce", c2)
+	check := FuncCall("length", c1)
+	create = CreateTable(t1).Columns(ColumnDef(c1, "text").Default(def).Check(check.Gt(0))).
+		Constraints(Check(check.Lt(100)))
+	create2 = create.Clone()
+	def.OrderBy(c1)
+	check.OrderBy(c2)
+	assert.Equal(t, `CREATE TABLE "public"."school" ("name" text DEFAULT coalesce("city") CHECK (length("name") > 0), CHECK (length("name") < 100))`,
+		stmtToSQL(ctx, create2))
+}
+
 func TestInsertStmt(t *testing.T) {
 	t1 := Table("public", "school")
 	c1 := Column(t1, "name")
@@ -88,9 +292,41 @@ func TestInsertStmt(t *testing.T) {
 	sql = stmtToSQL(ctx, stmt)
 	assert.Equal(t, exp2, sql)
 
+	sql = stmtToSQL(ctx, InsertInto(t1).DefaultValues().Returning(c1))
+	assert.Equal(t, `INSERT INTO "public"."school" DEFAULT VALUES RETURNING "school"."name"`, sql)
+
 	// TODO: More tests
 }
 
+func TestInsertStmt_OnConflict(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "deletedAt")
+
+	ctx := NewContext()
+	stmt := InsertInto(t1, c1, c2).Values("Abc", "Madison")
+	exp := `INSERT INTO "public"."school" ("name", "city") VALUES ('Abc', 'Madison')`
+
+	stmt.On(nil, DoNothing()).Returning(c1)
+	assert.Equal(t, exp+` ON CONFLICT DO NOTHING RETURNING "school"."name"`, stmtToSQL(ctx, stmt))
+
+	stmt = InsertInto(t1, c1, c2).Values("Abc", "Madison")
+	stmt.On(OnConstraint("school_name_key"), DoNothing())
+	assert.Equal(t, exp+` ON CONFLICT ON CONSTRAINT "school_name_key" DO NOTHING`, stmtToSQL(ctx, stmt))
+
+	stmt.On(ConflictExp(c1, FuncCall("lower", c2)).Where(c3.Is(Null)), DoUpdate(Set{
+		c2: Excluded(c2),
+	}).Where(c2.Ne(Excluded(c2))))
+	exp2 := exp + ` ON CONFLICT ("name", (lower("city"))) WHERE "deletedAt" IS NULL DO UPDATE SET "city" = "excluded"."city" WHERE "school"."city" != "excluded"."city"`
+	assert.Equal(t, exp2, stmtToSQL(ctx, stmt))
+
+	stmt2 := stmt.Make()
+	cols := []*ColumnNode{c2}
+	stmt.On(Conflict(cols...), DoNothing())
+	assert.Equal(t, exp2, stmtToSQL(ctx, stmt2))
+}
+
 func TestUpdateStmt(t *testing.T) {
 	t1 := Table("public", "school")
 	c1 := Column(t1, "name")
@@ -105,6 +341,80 @@ func TestUpdateStmt(t *testing.T) {
 	assert.Equal(t, `UPDATE "public"."school" SET "city" = 'Madison' WHERE "school"."enrollment" > 50000 AND "school"."name" != 'University of Wisconsin' RETURNING "school".*`, sql)
 }
 
+func TestUpdateStmt_Set(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+
+	ctx := NewContext()
+	stmt := Update(t1, Set{c2: "Madison"}).Where(c1.Eq(Arg("name")))
+	base := stmt.Make()
+	stmt.Set(Set{c3: 100, c2: "Seattle"}).Set(Set{c1: "Abc"})
+	assert.Equal(t, `UPDATE "public"."school" SET "city" = 'Seattle', "enrollment" = 100, "name" = 'Abc' WHERE "school"."name" = $1`, stmtToSQL(ctx, stmt))
+
+	stmt.Unset(c2, Column(t1, "name")).Where(c3.Gt(0))
+	assert.Equal(t, `UPDATE "public"."school" SET "enrollment" = 100 WHERE "school"."name" = $1 AND "school"."enrollment" > 0`, stmtToSQL(ctx, stmt))
+	assert.Equal(t, `UPDATE "public"."school" SET "city" = 'Madison' WHERE "school"."name" = $1`, stmtToSQL(ctx, base))
+
+	stmt.Unset(c3)
+	assert.Panics(t, func() {
+		ctx.ToSQL(stmt)
+	})
+}
+
+func TestUpdateStmt_SetForms(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+	tags := Column(t1, "tags")
+	meta := Column(t1, "meta")
+	t2 := Table("public", "city")
+
+	ctx := NewContext()
+	stmt := Update(t1, Set{
+		Columns(c1, c2):       Row("Abc", Default),
+		c3:                    Default,
+		Subscript(tags, 1):    "public",
+		Subscript(meta, "id"): Arg("id"),
+	})
+	sql := stmtToSQL(ctx, stmt)
+	assert.Equal(t, `UPDATE "public"."school" SET "enrollment" = DEFAULT, "meta"['id'] = $1, "tags"[1] = 'public', ("name", "city") = ('Abc', DEFAULT)`, sql)
+
+	sub := Select(Column(t2, "name"), Column(t2, "state")).From(t2).Where(Column(t2, "id").Eq(Column(t1, "cityId")))
+	stmt = Update(t1, Set{Columns(c2, Column(t1, "state")): SubQuery(sub)})
+	sql = stmtToSQL(ctx, stmt)
+	assert.Equal(t, `UPDATE "public"."school" SET ("city", "state") = (SELECT "city"."name", "city"."state" FROM "public"."city" WHERE "city"."id" = "school"."cityId" )`, sql)
+
+	// Subscripts by different arguments are different targets.
+	stmt = Update(t1, Set{Subscript(tags, Arg("i")): "a"}).Set(Set{Subscript(tags, Arg("j")): "b"}).
+		Set(Set{Subscript(tags, Arg("i")): "c"})
+	sql = stmtToSQL(ctx, stmt)
+	assert.Equal(t, `UPDATE "public"."school" SET "tags"[$1] = 'c', "tags"[$2] = 'b'`, sql)
+	stmt = Update(t1, Set{Subscript(tags, Arg("i")): "a", Subscript(tags, Arg("j")): "b"})
+	sql = stmtToSQL(ctx, stmt)
+	assert.Equal(t, `UPDATE "public"."school" SET "tags"[$1] = 'a', "tags"[$2] = 'b'`, sql)
+	assert.Panics(t, func() {
+		Update(t1, Set{Subscript(tags, Arg("")): "a"})
+	})
+}
+
+func TestUpdateStmt_ValuesTable(t *testing.T) {
+	t1 := Table("public", "school")
+	v := ValuesTable("v", "id", "city").ValuesInBulk([]interface{}{1, "Austin"}, []interface{}{2, "Boston"})
+
+	ctx := NewContext()
+	stmt := Update(t1, Set{
+		Column(t1, "city"): v.Column("city"),
+	}).Where(Column(t1, "id").Eq(v.Column("id")))
+	sql := stmtToSQL(ctx, stmt)
+	assert.Equal(t, `UPDATE "public"."school" SET "city" = "v"."city" FROM (VALUES (1, 'Austin'), (2, 'Boston')) "v"("id", "city") WHERE "school"."id" = "v"."id"`, sql)
+
+	sql = stmtToSQL(ctx, Select(Column(t1, "name"), v.Column("city")).From(t1.InnerJoin(v, Column(t1, "id").Eq(v.Column("id")))))
+	assert.Equal(t, `SELECT "school"."name", "v"."city" FROM "public"."school" INNER JOIN (VALUES (1, 'Austin'), (2, 'Boston')) "v"("id", "city") ON ("school"."id" = "v"."id")`, sql)
+}
+
 func TestDeleteStmt(t *testing.T) {
 	t1 := Table("public", "school")
 	c1 := Column(t1, "name")
@@ -128,3 +438,33 @@ func TestDeleteStmt(t *testing.T) {
 	assert.Equal(t, `DELETE FROM "public"."school" USING "public"."city" WHERE "city"."state" = $1 AND "city"."name" = "school"."city" RETURNING "school"."name", "school"."enrollment" > 40000`, sql)
 
 }
+
+func TestMergeStmt(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t1, "enrollment")
+	v := ValuesTable("v", "name", "city").Values("Abc", Arg("city"))
+
+	ctx := NewContext()
+	stmt := MergeInto(t1).Using(v, c1.Eq(v.Column("name"))).
+		WhenMatched(MergeDelete(), c3.Eq(0)).
+		WhenMatched(MergeUpdate(Set{c2: v.Column("city")})).
+		WhenNotMatched(MergeInsert(c1, c2).Values(v.Column("name"), v.Column("city"))).
+		Returning(c1)
+	sql := stmtToSQL(ctx, stmt)
+	assert.Equal(t, `MERGE INTO "public"."school" USING (VALUES ('Abc', $1)) "v"("name", "city") ON "school"."name" = "v"."name" WHEN MATCHED AND "school"."enrollment" = 0 THEN DELETE WHEN MATCHED THEN UPDATE SET "city" = "v"."city" WHEN NOT MATCHED THEN INSERT ("name", "city") VALUES ("v"."name", "v"."city") RETURNING "school"."name"`, sql)
+
+	t2 := Table("public", "staging")
+	stmt = MergeInto(t1.As("s")).Using(SubQueryTableExp(Select(Star(t2)).From(t2), "src"), Literal(false)).
+		WhenNotMatched(MergeInsert()).WhenNotMatchedBySource(MergeDoNothing())
+	sql = stmtToSQL(ctx, stmt)
+	assert.Equal(t, `MERGE INTO "public"."school" "s" USING (SELECT "staging".* FROM "public"."staging" ) "src" ON false WHEN NOT MATCHED THEN INSERT DEFAULT VALUES WHEN NOT MATCHED BY SOURCE THEN DO NOTHING`, sql)
+
+	assert.Panics(t, func() {
+		MergeInto(t1).WhenMatched(MergeInsert())
+	})
+	assert.Panics(t, func() {
+		MergeInto(t1).WhenNotMatched(MergeDelete())
+	})
+}
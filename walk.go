@@ -0,0 +1,369 @@
+package pgqb
+
+// Node of a statement tree, i.e. a Stmt, a Clause, a ColExp or a TableExp.
+type Node interface {
+	toSQL(ctx *buildContext)
+}
+
+// Clause of a statement (i.e. the WHERE clause), as passed to a Visitor.
+type Clause interface {
+	Node
+	// The keyword that starts the clause (i.e. "WHERE").
+	Keyword() string
+}
+
+// Same as ast.Visitor in the standard library: Visit is called for every node; if the returned visitor w is not
+// nil, the children of the node are visited with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Traverse the tree in depth-first order. Subqueries are traversed as well; the children of DDL statements are
+// their tables and expressions (i.e. CHECK, DEFAULT, index elements and predicates). Column sources are not
+// children of the columns referring to them.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	editChildren(node, func(child Node) Node {
+		Walk(v, child)
+		return child
+	})
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Traverse the tree in depth-first order, calling fn for every node (and with nil after the children of a
+// node). The children are skipped when fn returns false.
+func Inspect(node Node, fn func(Node) bool) {
+	Walk(inspector(fn), node)
+}
+
+// Return a copy of the tree in which every statement, ColExp and TableExp node is replaced by the result of fn,
+// from the leaves up; the original tree is not changed. fn receives copies of the nodes, so it may modify them
+// (i.e. add a predicate to a SelectStmt), and returns the node itself to keep it. A replacement must be usable
+// in place of the node (i.e. a ColExp for a ColExp, a *SelectStmt for a subquery); clauses cannot be replaced.
+func Rewrite(node Node, fn func(Node) Node) Node {
+	return rewrite(deepcopyNode(node), fn)
+}
+
+func rewrite(node Node, fn func(Node) Node) Node {
+	editChildren(node, func(child Node) Node {
+		return rewrite(child, fn)
+	})
+	switch node.(type) {
+	case Stmt, ColExp, TableExp:
+		return fn(node)
+	}
+	return node
+}
+
+func deepcopyNode(node Node) Node {
+	switch n := node.(type) {
+	case *ImmutableSelectStmt:
+		// Clone shares the statement.
+		return &ImmutableSelectStmt{stmt: n.stmt.Make()}
+	case Stmt:
+		return n.Clone()
+	case clause:
+		return n.deepcopy()
+	case TableExp:
+		return deepcopyTableExp(n)
+	case ColExp:
+		return deepcopyColExp(n)
+	case *ConflictTarget:
+		return n.deepcopy()
+	}
+	panic("unrecognizable node type")
+}
+
+// Call edit for every child of the node, replacing the child with the result when it is a different node.
+func editChildren(node Node, edit func(child Node) Node) {
+	colExp := func(exp *ColExp) {
+		if isNull(*exp) {
+			return
+		}
+		if res := edit(*exp); res != Node(*exp) {
+			replacement, ok := res.(ColExp)
+			if !ok {
+				panic("a ColExp can only be replaced by a ColExp")
+			}
+			*exp = replacement
+		}
+	}
+	colExps := func(exps []ColExp) {
+		for i := range exps {
+			colExp(&exps[i])
+		}
+	}
+	tableExp := func(exp *TableExp) {
+		if isNull(*exp) {
+			return
+		}
+		if res := edit(*exp); res != Node(*exp) {
+			replacement, ok := res.(TableExp)
+			if !ok {
+				panic("a TableExp can only be replaced by a TableExp")
+			}
+			*exp = replacement
+		}
+	}
+	// Typed children can only be replaced by nodes of the same type.
+	selectStmt := func(stmt **SelectStmt) {
+		if *stmt == nil {
+			return
+		}
+		if res := edit(*stmt); res != Node(*stmt) {
+			replacement, ok := res.(*SelectStmt)
+			if !ok {
+				panic("a subquery can only be replaced by a *SelectStmt")
+			}
+			*stmt = replacement
+		}
+	}
+	table := func(tb **TableNode) {
+		if res := edit(*tb); res != Node(*tb) {
+			replacement, ok := res.(*TableNode)
+			if !ok {
+				panic("the table can only be replaced by a *TableNode")
+			}
+			*tb = replacement
+		}
+	}
+	columns := func(cols []*ColumnNode) {
+		for i, col := range cols {
+			if res := edit(col); res != Node(col) {
+				replacement, ok := res.(*ColumnNode)
+				if !ok {
+					panic("the column can only be replaced by a *ColumnNode")
+				}
+				cols[i] = replacement
+			}
+		}
+	}
+	funcCall := func(fn **FuncCallNode) {
+		if res := edit(*fn); res != Node(*fn) {
+			replacement, ok := res.(*FuncCallNode)
+			if !ok {
+				panic("the function call can only be replaced by a *FuncCallNode")
+			}
+			*fn = replacement
+		}
+	}
+	childClause := func(c clause) {
+		if !isNull(c) {
+			edit(c)
+		}
+	}
+
+	if exp, ok := node.(TableExp); ok {
+		// Models are walked as their tables.
+		node = exp.node()
+	}
+	switch n := node.(type) {
+	// Statements.
+	case *SelectStmt:
+		for _, c := range []clause{n.selectClause, n.fromClause, n.whereClause, n.groupByClause, n.havingClause,
+			n.orderByClause} {
+			childClause(c)
+		}
+		colExp(&n.limit)
+		colExp(&n.offset)
+		colExp(&n.fetch)
+	case *ImmutableSelectStmt:
+		selectStmt(&n.stmt)
+	case *InsertStmt:
+		for _, c := range []clause{n.insertClause, n.defaultValuesClause, n.valuesClause, n.conflictClause,
+			n.returningClause} {
+			childClause(c)
+		}
+	case *UpdateStmt:
+		table(&n.table)
+		for _, c := range []clause{n.setClause, n.fromClause, n.whereClause, n.returningClause} {
+			childClause(c)
+		}
+	case *DeleteStmt:
+		table(&n.table)
+		for _, c := range []clause{n.usingClause, n.whereClause, n.returningClause} {
+			childClause(c)
+		}
+	case *MergeStmt:
+		tableExp(&n.target)
+		childClause(n.usingClause)
+		colExp(&n.onExp)
+		for _, when := range n.whenClauses {
+			childClause(when)
+		}
+		childClause(n.returningClause)
+
+	// DDL statements: the tables and the expressions (i.e. CHECK, DEFAULT, index elements and predicates).
+	case *CreateTableStmt:
+		table(&n.table)
+		for _, def := range n.columns {
+			colExp(&def.defaultExp)
+			colExp(&def.check)
+		}
+		for _, constraint := range n.constraints {
+			colExp(&constraint.check)
+		}
+		colExps(n.partitionKeys)
+	case *AlterTableStmt:
+		table(&n.table)
+		for _, action := range n.actions {
+			if action.def != nil {
+				colExp(&action.def.defaultExp)
+				colExp(&action.def.check)
+			}
+			if action.constraint != nil {
+				colExp(&action.constraint.check)
+			}
+			colExp(&action.exp)
+		}
+	case *DropTableStmt:
+		for i := range n.tables {
+			table(&n.tables[i])
+		}
+	case *CreateIndexStmt:
+		table(&n.table)
+		colExps(n.exps)
+		childClause(n.whereClause)
+	case *DropIndexStmt:
+		table(&n.index)
+
+	// Clauses.
+	case *selectClause:
+		colExps(n.colExpList)
+	case *returningClause:
+		colExps(n.colExpList)
+	case *groupByClause:
+		colExps(n.colExpList)
+	case *orderByClause:
+		colExps(n.colExpList)
+	case *defaultValuesClause:
+		colExps(n.colExpList)
+	case *whereClause:
+		colExp(&n.predicate)
+	case *havingClause:
+		colExp(&n.predicate)
+	case *fromClause:
+		for i := range n.tbExpList {
+			tableExp(&n.tbExpList[i])
+		}
+	case *usingClause:
+		for i := range n.tbExpList {
+			tableExp(&n.tbExpList[i])
+		}
+	case *setClause:
+		for _, assignment := range n.assignments {
+			if res := edit(assignment.target); res != Node(assignment.target) {
+				replacement, ok := res.(SetTarget)
+				if !ok {
+					panic("an assignment target can only be replaced by a SetTarget")
+				}
+				assignment.target = replacement
+			}
+			colExp(&assignment.value)
+		}
+	case *conflictClause:
+		if n.target != nil {
+			edit(n.target)
+		}
+		childClause(n.setClause)
+		childClause(n.whereClause)
+	case *insertClause:
+		table(&n.table)
+		columns(n.columns)
+	case *valuesClause:
+		for _, values := range n.valuesList {
+			colExps(values)
+		}
+	case *subqueryClause:
+		selectStmt(&n.selectStmt)
+	case *mergeWhenClause:
+		colExp(&n.condition)
+		childClause(n.action)
+	case *mergeAction:
+		childClause(n.setClause)
+		columns(n.columns)
+		colExps(n.values)
+	case *ConflictTarget:
+		colExps(n.exps)
+		childClause(n.whereClause)
+
+	// Table expressions.
+	case *TableAliasNode:
+		tb := n.table.(Node)
+		if res := edit(tb); res != tb {
+			replacement, ok := res.(ColSource)
+			if !ok {
+				panic("an aliased table can only be replaced by a ColSource")
+			}
+			n.table = replacement
+		}
+	case *JoinNode:
+		tableExp(&n.src)
+		tableExp(&n.dst)
+		colExp(&n.exp)
+		columns(n.usingCols)
+	case *LateralNode:
+		tableExp(&n.exp)
+	case *SubQueryTableExpNode:
+		selectStmt(&n.selectStmt)
+	case *ValuesTableExpNode:
+		for _, values := range n.valuesList {
+			colExps(values)
+		}
+	case *TableFuncNode:
+		for i := range n.funcs {
+			funcCall(&n.funcs[i])
+		}
+
+	// Column expressions.
+	case *SQLNode:
+		colExps(n.args)
+	case *ColExpAliasNode:
+		colExp(&n.exp)
+	case *GroupExpNode:
+		colExp(&n.exp)
+	case *UnaryExpNode:
+		colExp(&n.exp)
+	case *OrderExpNode:
+		colExp(&n.exp)
+	case *CollateNode:
+		colExp(&n.exp)
+	case *BinaryExpNode:
+		colExp(&n.left)
+		colExp(&n.right)
+	case *MultiExpNode:
+		colExps(n.expList)
+	case *LogicalExpNode:
+		colExps(n.expList)
+	case *RowNode:
+		colExps(n.expList)
+	case *FuncCallNode:
+		colExps(n.expList)
+		childClause(n.orderBy)
+		childClause(n.withinGroup)
+		childClause(n.filter)
+	case *GroupingSetsNode:
+		for _, set := range n.sets {
+			colExps(set)
+		}
+	case *ColumnListNode:
+		columns(n.cols)
+	case *SubscriptNode:
+		colExp(&n.exp)
+		colExp(&n.index)
+		colExp(&n.upper)
+	case *SubQueryColExpNode:
+		selectStmt(&n.selectStmt)
+	}
+}
@@ -0,0 +1,127 @@
+package pgqb
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	t1 := Table("public", "school")
+	t2 := Table("public", "city")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t2, "name")
+
+	stmt := Select(c1).From(t1).Where(Exists(Select(c3).From(t2).Where(c3.Eq(c2))))
+	var tables, keywords []string
+	depth := 0
+	Inspect(stmt, func(node Node) bool {
+		switch n := node.(type) {
+		case nil:
+			depth--
+			return false
+		case *TableNode:
+			tables = append(tables, n.Schema()+"."+n.Name())
+		case Clause:
+			keywords = append(keywords, n.Keyword())
+		}
+		depth++
+		return true
+	})
+	assert.Equal(t, []string{"public.school", "public.city"}, tables)
+	assert.Equal(t, []string{"SELECT", "FROM", "WHERE", "SELECT", "FROM", "WHERE"}, keywords)
+	assert.Equal(t, 0, depth)
+
+	// The children are skipped when fn returns false.
+	var columns []string
+	Inspect(stmt, func(node Node) bool {
+		if col, ok := node.(*ColumnNode); ok {
+			columns = append(columns, col.Name())
+		}
+		_, isSubQuery := node.(*SubQueryColExpNode)
+		return !isSubQuery
+	})
+	assert.Equal(t, []string{"name"}, columns)
+}
+
+func TestRewrite(t *testing.T) {
+	t1 := Table("public", "school")
+	t2 := Table("public", "city")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "city")
+	c3 := Column(t2, "name")
+
+	ctx := NewContext()
+	stmt := Select(c1).From(t1).Where(c2.In(SubQuery(Select(c3).From(t2).Where(c3.Ne("Seattle")))))
+	orig := stmtToSQL(ctx, stmt)
+
+	// Inject a predicate into every SELECT, subqueries included.
+	res := Rewrite(stmt, func(node Node) Node {
+		if s, ok := node.(*SelectStmt); ok {
+			return s.Where(SQL("tenant_id = 1"))
+		}
+		return node
+	})
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."city" IN (SELECT "city"."name" FROM "public"."city" WHERE "city"."name" != 'Seattle' AND tenant_id = 1 ) AND tenant_id = 1`,
+		stmtToSQL(ctx, res.(Stmt)))
+	assert.Equal(t, orig, stmtToSQL(ctx, stmt))
+
+	// Replace a column.
+	res = Rewrite(stmt, func(node Node) Node {
+		if node == Node(c3) {
+			return Column(t2, "code")
+		}
+		return node
+	})
+	assert.Equal(t, `SELECT "school"."name" FROM "public"."school" WHERE "school"."city" IN (SELECT "city"."code" FROM "public"."city" WHERE "city"."code" != 'Seattle' )`,
+		stmtToSQL(ctx, res.(Stmt)))
+	assert.Equal(t, orig, stmtToSQL(ctx, stmt))
+
+	// Replacements must be usable in place of the node.
+	assert.Panics(t, func() {
+		Rewrite(InsertInto(t1, c1).Values("Lincoln"), func(node Node) Node {
+			if node == Node(c1) {
+				return c1.Eq("x")
+			}
+			return node
+		})
+	})
+}
+
+func TestRewrite_DDL(t *testing.T) {
+	t1 := Table("public", "school")
+	c1 := Column(t1, "name")
+	c2 := Column(t1, "enrollment")
+
+	ctx := NewContext()
+	create := CreateTable(t1).Columns(
+		ColumnDef(c1, "text").Default(""),
+		ColumnDef(c2, "int").Check(c2.Gte(0)),
+	).Constraints(Check(c1.Ne("")))
+	index := CreateIndex("school_name", t1, FuncCall("lower", c1)).Where(c2.Gt(0))
+	alter := AlterTable(t1).AlterColumnType(c2, "bigint", SQL("?::bigint", c2))
+	for stmt, exp := range map[Stmt]string{
+		create: `CREATE TABLE "public"."school" ("name" text DEFAULT '', "enrollment" int CHECK ("students" >= 0), CHECK ("name" != ''))`,
+		index:  `CREATE INDEX "school_name" ON "public"."school" (lower("name")) WHERE "students" > 0`,
+		alter:  `ALTER TABLE "public"."school" ALTER COLUMN "enrollment" TYPE bigint USING "students"::bigint`,
+	} {
+		orig := stmtToSQL(ctx, stmt)
+		res := Rewrite(stmt, func(node Node) Node {
+			if node == Node(c2) {
+				return Column(t1, "students")
+			}
+			return node
+		})
+		assert.Equal(t, exp, stmtToSQL(ctx, res.(Stmt)))
+		assert.Equal(t, orig, stmtToSQL(ctx, stmt))
+	}
+
+	var keywords []string
+	Inspect(index, func(node Node) bool {
+		if c, ok := node.(Clause); ok {
+			keywords = append(keywords, c.Keyword())
+		}
+		return true
+	})
+	assert.Equal(t, []string{"WHERE"}, keywords)
+}